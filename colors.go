@@ -14,4 +14,7 @@ var (
 	DarkGreenColor = color.NRGBA{A: 0xff, R: 39, G: 83, B: 23}
 	LightGreyColor = color.NRGBA{A: 0xff, R: 210, G: 210, B: 210}
 	PinkColor      = color.NRGBA{A: 0xff, R: 220, G: 138, B: 255}
+	HighlightColor = color.NRGBA{A: 0xff, R: 230, G: 200, B: 90}
+	LogErrorColor  = color.NRGBA{A: 0xff, R: 255, G: 110, B: 110}
+	DiffAddColor   = color.NRGBA{A: 0xff, R: 120, G: 220, B: 120}
 )
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SeungKang/wgui/internal/atomicfile"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+)
+
+const (
+	// maxConfigBackups is the number of previous versions
+	// kept for each profile.
+	maxConfigBackups = 10
+
+	// configBackupsDirName is the hidden directory in the config
+	// directory that previous versions of configs are kept in.
+	configBackupsDirName = ".backups"
+
+	// configBackupTimeFormat names backups by when they were
+	// replaced, so that sorting them by name sorts them by time.
+	configBackupTimeFormat = "20060102T150405.000000000"
+)
+
+// configBackup is a previous version of a profile's config.
+type configBackup struct {
+	path       string
+	replacedAt time.Time
+}
+
+// configBackupDir returns the directory with the backups of the
+// named profile's config.
+func (s *State) configBackupDir(profileName string) string {
+	return filepath.Join(s.wguConfDir, configBackupsDirName, profileName)
+}
+
+// listConfigBackups returns the backups in dir, newest first.
+func listConfigBackups(dir string) ([]configBackup, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backups directory - %w", err)
+	}
+
+	var backups []configBackup
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		replacedAt, err := time.Parse(configBackupTimeFormat, strings.TrimSuffix(entry.Name(), ".conf"))
+		if err != nil {
+			continue
+		}
+
+		backups = append(backups, configBackup{
+			path:       filepath.Join(dir, entry.Name()),
+			replacedAt: replacedAt,
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].replacedAt.After(backups[j].replacedAt)
+	})
+
+	return backups, nil
+}
+
+// backupConfig copies the config at configPath into its profile's
+// backups before it is replaced, unless it has not changed since the
+// newest backup. Only the newest maxConfigBackups backups are kept.
+func (s *State) backupConfig(configPath string) error {
+	data, err := os.ReadFile(configPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to read config - %w", err)
+	}
+
+	dir := s.configBackupDir(profileNameForPath(configPath))
+
+	backups, err := listConfigBackups(dir)
+	if err != nil {
+		return err
+	}
+
+	if len(backups) > 0 {
+		newest, err := os.ReadFile(backups[0].path)
+		if err == nil && bytes.Equal(newest, data) {
+			return nil
+		}
+	}
+
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return fmt.Errorf("failed to create backups directory - %w", err)
+	}
+
+	// Backups are named by time, so make sure that a backup made
+	// right after another does not replace it on platforms with
+	// a coarse clock
+	replacedAt := time.Now().UTC()
+	if len(backups) > 0 && !replacedAt.After(backups[0].replacedAt) {
+		replacedAt = backups[0].replacedAt.Add(time.Nanosecond)
+	}
+
+	name := replacedAt.Format(configBackupTimeFormat) + ".conf"
+
+	err = atomicfile.WriteFile(filepath.Join(dir, name), data, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write backup - %w", err)
+	}
+
+	return pruneConfigBackups(dir)
+}
+
+// pruneConfigBackups removes all but the newest maxConfigBackups
+// backups in dir.
+func pruneConfigBackups(dir string) error {
+	backups, err := listConfigBackups(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, backup := range backups[min(len(backups), maxConfigBackups):] {
+		err = os.Remove(backup.path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove old backup - %w", err)
+		}
+	}
+
+	return nil
+}
+
+// moveConfigBackups moves a profile's backups along with it when
+// it is renamed.
+func (s *State) moveConfigBackups(oldName string, newName string) error {
+	oldDir := s.configBackupDir(oldName)
+	newDir := s.configBackupDir(newName)
+
+	backups, err := listConfigBackups(oldDir)
+	if err != nil || len(backups) == 0 {
+		return err
+	}
+
+	err = os.MkdirAll(newDir, 0700)
+	if err != nil {
+		return fmt.Errorf("failed to create backups directory - %w", err)
+	}
+
+	for _, backup := range backups {
+		err = os.Rename(backup.path, filepath.Join(newDir, filepath.Base(backup.path)))
+		if err != nil {
+			return fmt.Errorf("failed to move backup - %w", err)
+		}
+	}
+
+	// This fails harmlessly if only the case of the name changed
+	// on a case-insensitive file system
+	_ = os.Remove(oldDir)
+
+	return pruneConfigBackups(newDir)
+}
+
+// restorePreviousVersion puts the next older backup of the profile
+// being edited in the config editor, skipping backups that are the
+// same as the editor's contents. It is only written once it is saved.
+func (s *State) restorePreviousVersion() error {
+	backups, err := listConfigBackups(s.configBackupDir(profileNameForPath(s.editPath)))
+	if err != nil {
+		return err
+	}
+
+	for s.restoredBackups < len(backups) {
+		backup := backups[s.restoredBackups]
+		s.restoredBackups++
+
+		data, err := os.ReadFile(backup.path)
+		if err != nil {
+			return fmt.Errorf("failed to read backup - %w", err)
+		}
+
+		if string(data) == s.configEditor.Text() {
+			continue
+		}
+
+		s.configEditor.SetText(string(data))
+		s.formInfoMsg = fmt.Sprintf("Restored the version replaced at %s - save to keep it",
+			backup.replacedAt.Local().Format(time.DateTime))
+
+		return nil
+	}
+
+	s.formInfoMsg = "There are no older versions of this profile"
+
+	return nil
+}
+
+// renderRestoreButton shows a button that restores the previous
+// version of the profile being edited
+func (s *State) renderRestoreButton(gtx layout.Context) layout.Dimensions {
+	onClick := func() {
+		err := s.restorePreviousVersion()
+		if err != nil {
+			s.errLabel = err.Error()
+			s.errLogger.Printf("failed to restore previous version - %v", err)
+		}
+	}
+
+	return layout.Inset{Left: unit.Dp(12)}.Layout(gtx, func(gtx C) D {
+		return s.renderButton(gtx, "Restore Previous Version", GreyColor, s.restoreButton, onClick)
+	})
+}
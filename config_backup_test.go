@@ -0,0 +1,131 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"gioui.org/widget"
+)
+
+func TestState_BackupConfig(t *testing.T) {
+	s := newTestState(t)
+
+	configPath := filepath.Join(s.wguConfDir, "test.conf")
+	backupDir := s.configBackupDir("test")
+
+	// There is nothing to back up before the first save
+	err := s.backupConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < maxConfigBackups+2; i++ {
+		err = os.WriteFile(configPath, []byte("version "+strconv.Itoa(i)+"\n"), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = s.backupConfig(configPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Unchanged configs are only backed up once
+		err = s.backupConfig(configPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups, err := listConfigBackups(backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(backups) != maxConfigBackups {
+		t.Fatalf("got %d backups, want %d", len(backups), maxConfigBackups)
+	}
+
+	newest, err := os.ReadFile(backups[0].path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "version " + strconv.Itoa(maxConfigBackups+1) + "\n"; string(newest) != want {
+		t.Fatalf("newest backup: got %q, want %q", newest, want)
+	}
+}
+
+func TestState_RestorePreviousVersion(t *testing.T) {
+	s := newTestState(t)
+	s.configEditor = new(widget.Editor)
+
+	configPath := filepath.Join(s.wguConfDir, "test.conf")
+
+	for _, contents := range []string{"first\n", "second\n", "third\n"} {
+		err := s.backupConfig(configPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.WriteFile(configPath, []byte(contents), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s.startEditing(configPath, "third\n")
+	s.configEditor.SetText("second\n")
+
+	// The newest backup is already in the editor, so it is skipped
+	for _, want := range []string{"first\n", "first\n"} {
+		err := s.restorePreviousVersion()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := s.configEditor.Text(); got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+
+	if s.formInfoMsg != "There are no older versions of this profile" {
+		t.Fatalf("unexpected info message: %q", s.formInfoMsg)
+	}
+}
+
+func TestState_MoveConfigBackups(t *testing.T) {
+	s := newTestState(t)
+
+	configPath := filepath.Join(s.wguConfDir, "old.conf")
+
+	err := os.WriteFile(configPath, []byte("[Interface]\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.backupConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.moveConfigBackups("old", "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(s.configBackupDir("old")); !os.IsNotExist(err) {
+		t.Fatalf("old backups directory was left behind - %v", err)
+	}
+
+	backups, err := listConfigBackups(s.configBackupDir("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(backups) != 1 {
+		t.Fatalf("got %d backups, want 1", len(backups))
+	}
+}
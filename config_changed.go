@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+
+	"github.com/SeungKang/wgui/internal/linediff"
+	"github.com/SeungKang/wgui/internal/wguctl"
+
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+)
+
+// configDiffContext is the number of unchanged lines shown
+// around each change in the config changed banner.
+const configDiffContext = 2
+
+// configDiff caches the diff between the config a profile's wgu was
+// started with and the config on disk, so that it is only recomputed
+// when either changes.
+type configDiff struct {
+	running string
+	current string
+	hunks   [][]linediff.Line
+}
+
+// update recomputes the diff if running or current changed. It
+// returns false if they are the same.
+func (o *configDiff) update(running string, current string) bool {
+	if running != o.running || current != o.current {
+		o.running = running
+		o.current = current
+		o.hunks = nil
+
+		lines := linediff.Diff(linediff.SplitLines(running), linediff.SplitLines(current))
+		if linediff.HasChanges(lines) {
+			o.hunks = linediff.Hunks(lines, configDiffContext)
+		}
+	}
+
+	return len(o.hunks) > 0
+}
+
+// configChangedSinceConnect reports whether the selected profile's
+// config changed on disk after its wgu was started.
+func (s *State) configChangedSinceConnect() bool {
+	selected := s.profiles.selected()
+	if selected.state.To != wguctl.ConnectedFsmState || selected.lastReadConfig == "" {
+		return false
+	}
+
+	running, ok := selected.wgu.RunningConfig()
+	if !ok {
+		return false
+	}
+
+	return selected.runningDiff.update(running, selected.lastReadConfig)
+}
+
+// renderConfigChangedBanner warns that the connected profile's config
+// changed on disk, and offers to show the changes and to reconnect
+// with them
+func (s *State) renderConfigChangedBanner(ctx context.Context, gtx layout.Context) layout.Dimensions {
+	if !s.configChangedSinceConnect() {
+		return D{}
+	}
+
+	for s.showConfigDiffButton.Clicked(gtx) {
+		s.showConfigDiff = !s.showConfigDiff
+	}
+
+	reconnect := func() {
+		selected := s.profiles.selected()
+
+		// Events are handled in order, so wgu is stopped
+		// before it is started with the new config
+		_ = selected.wgu.Disconnect(ctx)
+		_ = selected.wgu.Connect(ctx, s.wguConfigFor(selected))
+	}
+
+	diffLabel := "Show Changes"
+	if s.showConfigDiff {
+		diffLabel = "Hide Changes"
+	}
+
+	return layout.Inset{Left: unit.Dp(16), Right: unit.Dp(16), Bottom: unit.Dp(8)}.Layout(gtx, func(gtx C) D {
+		return layout.Background{}.Layout(gtx,
+			func(gtx C) D {
+				paint.FillShape(gtx.Ops, SelectedBg, clip.Rect{Max: gtx.Constraints.Min}.Op())
+				return D{Size: gtx.Constraints.Min}
+			},
+			func(gtx C) D {
+				return layout.UniformInset(unit.Dp(8)).Layout(gtx, func(gtx C) D {
+					return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+						layout.Rigid(func(gtx C) D {
+							return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+								layout.Flexed(1, func(gtx C) D {
+									label := material.Label(s.theme, 14, "Config changed since connect")
+									label.Color = HighlightColor
+									return label.Layout(gtx)
+								}),
+								layout.Rigid(func(gtx C) D {
+									return s.renderSmallButton(gtx, s.showConfigDiffButton, diffLabel)
+								}),
+								layout.Rigid(func(gtx C) D {
+									return layout.Inset{Left: unit.Dp(8)}.Layout(gtx, func(gtx C) D {
+										return s.renderButton(gtx, "Reconnect", PurpleColor, s.reconnectChangedButton, reconnect)
+									})
+								}),
+							)
+						}),
+						layout.Rigid(func(gtx C) D {
+							if !s.showConfigDiff {
+								return D{}
+							}
+
+							return s.renderConfigDiff(gtx, s.profiles.selected().runningDiff.hunks)
+						}),
+					)
+				})
+			},
+		)
+	})
+}
+
+// renderConfigDiff shows the changed lines of a config diff
+func (s *State) renderConfigDiff(gtx layout.Context, hunks [][]linediff.Line) layout.Dimensions {
+	var rows []linediff.Line
+	for i, hunk := range hunks {
+		if i > 0 {
+			rows = append(rows, linediff.Line{Text: "..."})
+		}
+
+		rows = append(rows, hunk...)
+	}
+
+	gtx.Constraints.Max.Y = min(gtx.Constraints.Max.Y, gtx.Dp(unit.Dp(200)))
+
+	return layout.Inset{Top: unit.Dp(8)}.Layout(gtx, func(gtx C) D {
+		return material.List(s.theme, s.configDiffList).Layout(gtx, len(rows), func(gtx C, i int) D {
+			label := s.logText(rows[i].String())
+
+			switch rows[i].Op {
+			case linediff.Insert:
+				label.Color = DiffAddColor
+			case linediff.Delete:
+				label.Color = LogErrorColor
+			}
+
+			return label.Layout(gtx)
+		})
+	})
+}
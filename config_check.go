@@ -0,0 +1,325 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/SeungKang/wgui/internal/wguctl/wgconf"
+
+	"gioui.org/io/key"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// configCheckDelay is how long the config must stay unchanged while
+// typing before it is validated again.
+const configCheckDelay = 400 * time.Millisecond
+
+// configCheck validates the config editor's text as it changes.
+type configCheck struct {
+	text        string
+	dueAt       time.Time
+	diagnostics []wgconf.Diagnostic
+
+	// lines is the diagnostics grouped by the line they are on.
+	lines []lineDiagnostics
+
+	// clicks has a Clickable for each of lines.
+	clicks       []widget.Clickable
+	summaryClick widget.Clickable
+}
+
+// lineDiagnostics is the diagnostics on one line of the config.
+type lineDiagnostics struct {
+	line int
+
+	// column is the column of the line's first diagnostic.
+	column int
+
+	// severity is the most severe of the line's diagnostics.
+	severity wgconf.Severity
+
+	msg string
+}
+
+// groupDiagnosticsByLine combines diagnostics that are on the
+// same line. The diagnostics must be sorted by position.
+func groupDiagnosticsByLine(diagnostics []wgconf.Diagnostic) []lineDiagnostics {
+	var lines []lineDiagnostics
+
+	for _, diagnostic := range diagnostics {
+		msg := diagnostic.Severity.String() + ": " + diagnostic.Msg
+
+		if n := len(lines); n > 0 && lines[n-1].line == diagnostic.Line {
+			last := &lines[n-1]
+			last.severity = max(last.severity, diagnostic.Severity)
+			last.msg += "; " + msg
+			continue
+		}
+
+		lines = append(lines, lineDiagnostics{
+			line:     diagnostic.Line,
+			column:   diagnostic.Column,
+			severity: diagnostic.Severity,
+			msg:      msg,
+		})
+	}
+
+	return lines
+}
+
+// update schedules validation when the editor's text changes, and
+// runs it once the text has been left alone for configCheckDelay.
+func (o *configCheck) update(gtx layout.Context, editor *widget.Editor) {
+	text := editor.Text()
+	if text != o.text {
+		o.text = text
+		o.dueAt = gtx.Now.Add(configCheckDelay)
+	}
+
+	if o.dueAt.IsZero() {
+		return
+	}
+
+	if gtx.Now.Before(o.dueAt) {
+		gtx.Execute(op.InvalidateCmd{At: o.dueAt})
+		return
+	}
+
+	o.run()
+}
+
+// run validates the current text immediately.
+func (o *configCheck) run() {
+	o.dueAt = time.Time{}
+
+	if strings.TrimSpace(o.text) == "" {
+		o.diagnostics = nil
+		o.lines = nil
+		return
+	}
+
+	o.diagnostics = wgconf.Validate([]byte(o.text))
+	o.lines = groupDiagnosticsByLine(o.diagnostics)
+
+	if len(o.clicks) < len(o.lines) {
+		o.clicks = make([]widget.Clickable, len(o.lines))
+	}
+}
+
+// validate validates text immediately and returns the diagnostics.
+func (o *configCheck) validate(text string) []wgconf.Diagnostic {
+	o.text = text
+	o.run()
+
+	return o.diagnostics
+}
+
+// renderConfigField shows the config editor with its problems
+// marked on the lines they were found on.
+func (s *State) renderConfigField(gtx layout.Context) layout.Dimensions {
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx C) D {
+			return s.renderFieldLabel(gtx, "Config")
+		}),
+		layout.Rigid(func(gtx C) D {
+			return s.renderTextEditorWithOverlay(gtx, s.configEditor, "", unit.Dp(300),
+				s.renderConfigDiagnosticMarkers)
+		}),
+	)
+}
+
+// renderConfigDiagnosticMarkers tints the config editor's lines that
+// have problems, underlines them from where the problem starts and
+// shows the problems after the line's text. Clicking a problem
+// moves the editor's caret to it. It is laid out over the editor,
+// after the editor, so that the lines' positions are known.
+func (s *State) renderConfigDiagnosticMarkers(gtx layout.Context) layout.Dimensions {
+	text := s.configEditor.Text()
+	width := gtx.Constraints.Max.X
+
+	var lineRegions, underlineRegions []widget.Region
+
+	for i := range s.configCheck.lines {
+		diagnostics := s.configCheck.lines[i]
+		click := &s.configCheck.clicks[i]
+
+		for click.Clicked(gtx) {
+			moveCaretTo(s.configEditor, diagnostics.line, diagnostics.column)
+			gtx.Execute(key.FocusCmd{Tag: s.configEditor})
+		}
+
+		start := runeOffset(text, diagnostics.line, 1)
+		end := runeOffset(text, diagnostics.line, math.MaxInt)
+
+		// Empty lines have no glyphs, so use their line ending
+		lineRegions = s.configEditor.Regions(start, max(end, start+1), lineRegions[:0])
+		if len(lineRegions) == 0 {
+			// The line is scrolled out of view
+			continue
+		}
+
+		top := lineRegions[0].Bounds.Min.Y
+		bottom := lineRegions[len(lineRegions)-1].Bounds.Max.Y
+		textEnd := lineRegions[len(lineRegions)-1].Bounds.Max.X
+
+		markerColor := diagnosticColor(diagnostics.severity)
+
+		tint := markerColor
+		tint.A = 0x30
+		paint.FillShape(gtx.Ops, tint, clip.Rect{Min: image.Pt(0, top), Max: image.Pt(width, bottom)}.Op())
+
+		column := runeOffset(text, diagnostics.line, diagnostics.column)
+		underlineRegions = s.configEditor.Regions(column, end, underlineRegions[:0])
+
+		thickness := max(gtx.Dp(unit.Dp(1)), 1)
+		for _, region := range underlineRegions {
+			paint.FillShape(gtx.Ops, markerColor, clip.Rect{
+				Min: image.Pt(region.Bounds.Min.X, region.Bounds.Max.Y-thickness),
+				Max: region.Bounds.Max,
+			}.Op())
+		}
+
+		// Show the message after the line's text, or over the end
+		// of the line if there is not enough room
+		msgX := textEnd + gtx.Dp(unit.Dp(16))
+		if minWidth := gtx.Dp(unit.Dp(160)); width-msgX < minWidth {
+			msgX = max(width-minWidth, 0)
+		}
+
+		msgGtx := gtx
+		msgGtx.Constraints = layout.Constraints{Max: image.Pt(width-msgX, gtx.Constraints.Max.Y)}
+
+		offset := op.Offset(image.Pt(msgX, top)).Push(gtx.Ops)
+		click.Layout(msgGtx, func(gtx C) D {
+			label := material.Label(s.theme, 12, diagnostics.msg)
+			label.Color = markerColor
+			label.MaxLines = 1
+
+			macro := op.Record(gtx.Ops)
+			dims := label.Layout(gtx)
+			call := macro.Stop()
+
+			paint.FillShape(gtx.Ops, GreyColor, clip.Rect{Max: dims.Size}.Op())
+			call.Add(gtx.Ops)
+
+			return dims
+		})
+		offset.Pop()
+	}
+
+	return D{Size: gtx.Constraints.Max}
+}
+
+// renderConfigDiagnostics summarizes the problems found in the config,
+// since they are only marked on the lines that are in view. Clicking
+// it moves the editor's caret to the first problem.
+func (s *State) renderConfigDiagnostics(gtx layout.Context) layout.Dimensions {
+	lines := s.configCheck.lines
+	if len(lines) == 0 {
+		return D{}
+	}
+
+	for s.configCheck.summaryClick.Clicked(gtx) {
+		moveCaretTo(s.configEditor, lines[0].line, lines[0].column)
+		gtx.Execute(key.FocusCmd{Tag: s.configEditor})
+	}
+
+	return layout.Inset{Top: unit.Dp(6)}.Layout(gtx, func(gtx C) D {
+		return s.configCheck.summaryClick.Layout(gtx, func(gtx C) D {
+			severity := wgconf.WarningSeverity
+			if wgconf.HasErrors(s.configCheck.diagnostics) {
+				severity = wgconf.ErrorSeverity
+			}
+
+			msg := diagnosticsSummary(s.configCheck.diagnostics) + fmt.Sprintf(" - first on line %d", lines[0].line)
+
+			label := material.Label(s.theme, 12, msg)
+			label.Color = diagnosticColor(severity)
+
+			return label.Layout(gtx)
+		})
+	})
+}
+
+// diagnosticsSummary counts the errors and warnings in diagnostics,
+// such as "1 error and 2 warnings".
+func diagnosticsSummary(diagnostics []wgconf.Diagnostic) string {
+	var numErrors, numWarnings int
+
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity == wgconf.ErrorSeverity {
+			numErrors++
+		} else {
+			numWarnings++
+		}
+	}
+
+	var parts []string
+	if numErrors > 0 {
+		parts = append(parts, plural(numErrors, "error"))
+	}
+	if numWarnings > 0 {
+		parts = append(parts, plural(numWarnings, "warning"))
+	}
+
+	return strings.Join(parts, " and ")
+}
+
+// plural formats a count of things, such as "1 error" or "2 errors".
+func plural(n int, thing string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, thing)
+	}
+
+	return fmt.Sprintf("%d %ss", n, thing)
+}
+
+// diagnosticColor is the color problems of a severity are shown in.
+func diagnosticColor(severity wgconf.Severity) color.NRGBA {
+	if severity == wgconf.ErrorSeverity {
+		return LogErrorColor
+	}
+
+	return HighlightColor
+}
+
+// moveCaretTo moves the editor's caret to a 1-based line and column.
+func moveCaretTo(editor *widget.Editor, line int, column int) {
+	offset := runeOffset(editor.Text(), line, column)
+
+	editor.SetCaret(offset, offset)
+}
+
+// runeOffset returns the rune offset of a 1-based line and column
+// in text, which is how the editor measures positions. Columns past
+// the end of the line are clamped to it.
+func runeOffset(text string, line int, column int) int {
+	offset := 0
+	for i := 1; i < line; i++ {
+		next := strings.IndexByte(text[offset:], '\n')
+		if next < 0 {
+			break
+		}
+
+		offset += next + 1
+	}
+
+	lineText := text[offset:]
+	if end := strings.IndexByte(lineText, '\n'); end >= 0 {
+		lineText = lineText[:end]
+	}
+
+	col := min(max(column-1, 0), len(lineText))
+
+	return len([]rune(text[:offset])) + len([]rune(lineText[:col]))
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/SeungKang/wgui/internal/wguctl/wgconf"
+)
+
+func TestGroupDiagnosticsByLine(t *testing.T) {
+	diagnostics := []wgconf.Diagnostic{
+		{Severity: wgconf.WarningSeverity, Line: 2, Column: 1, Msg: "a"},
+		{Severity: wgconf.ErrorSeverity, Line: 2, Column: 5, Msg: "b"},
+		{Severity: wgconf.WarningSeverity, Line: 4, Column: 3, Msg: "c"},
+	}
+
+	got := groupDiagnosticsByLine(diagnostics)
+
+	want := []lineDiagnostics{
+		{line: 2, column: 1, severity: wgconf.ErrorSeverity, msg: "warning: a; error: b"},
+		{line: 4, column: 3, severity: wgconf.WarningSeverity, msg: "warning: c"},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("line %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRuneOffset(t *testing.T) {
+	const text = "[Interface]\nPrivateKey = é\n\nMTU = 1420"
+
+	tests := []struct {
+		line   int
+		column int
+		want   int
+	}{
+		{line: 1, column: 1, want: 0},
+		{line: 2, column: 1, want: 12},
+		{line: 2, column: 14, want: 25},
+		{line: 2, column: math.MaxInt, want: 26},
+		{line: 3, column: 1, want: 27},
+		{line: 4, column: 7, want: 34},
+		{line: 10, column: 1, want: 28},
+	}
+
+	for _, test := range tests {
+		if got := runeOffset(text, test.line, test.column); got != test.want {
+			t.Errorf("runeOffset(%d, %d): got %d, want %d", test.line, test.column, got, test.want)
+		}
+	}
+}
+
+func TestDiagnosticsSummary(t *testing.T) {
+	tests := []struct {
+		severities []wgconf.Severity
+		want       string
+	}{
+		{[]wgconf.Severity{wgconf.ErrorSeverity}, "1 error"},
+		{[]wgconf.Severity{wgconf.WarningSeverity, wgconf.WarningSeverity}, "2 warnings"},
+		{[]wgconf.Severity{wgconf.ErrorSeverity, wgconf.ErrorSeverity, wgconf.WarningSeverity}, "2 errors and 1 warning"},
+	}
+
+	for _, test := range tests {
+		var diagnostics []wgconf.Diagnostic
+		for _, severity := range test.severities {
+			diagnostics = append(diagnostics, wgconf.Diagnostic{Severity: severity})
+		}
+
+		if got := diagnosticsSummary(diagnostics); got != test.want {
+			t.Errorf("got %q, want %q", got, test.want)
+		}
+	}
+}
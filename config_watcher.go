@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/SeungKang/wgui/internal/dirwatch"
+)
+
+// configWatchInterval is how often the config directory is checked
+// for changes made outside of wgui.
+const configWatchInterval = 2 * time.Second
+
+// watchConfigDir publishes changes to the config directory on the
+// event bus until ctx is done.
+func (s *State) watchConfigDir(ctx context.Context, watcher *dirwatch.Watcher) {
+	watcher.Run(ctx, configWatchInterval, s.bus.publishConfigChanges, func(err error) {
+		s.errLogger.Printf("failed to check config directory for changes - %v", err)
+	})
+}
+
+// applyConfigChanges reloads the profiles after configs were added,
+// removed, renamed or modified. Reloading recognizes renamed configs
+// itself, since profiles can be reloaded before the watcher sees a
+// change, so renamed profiles keep their Fsm, logs and connection
+// state, and stay selected if they were selected.
+func (s *State) applyConfigChanges(ctx context.Context, _ dirwatch.Changes) {
+	_ = s.RefreshProfiles(ctx)
+}
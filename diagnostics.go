@@ -0,0 +1,253 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SeungKang/wgui/internal/rotlog"
+	"github.com/SeungKang/wgui/internal/wguctl"
+)
+
+// secretKeyRe matches config lines holding secret keys, including
+// commented out ones. It stops at the end of the line, so that the
+// '\r' of CRLF line endings is kept.
+var secretKeyRe = regexp.MustCompile(`(?im)^([ \t]*(?:[#;][ \t]*)*(?:PrivateKey|PresharedKey)[ \t]*=[ \t]*)([^ \t\r\n][^\r\n]*)`)
+
+// secretTextRe matches secret keys mentioned anywhere in a line,
+// such as wgu quoting a config line in an error.
+var secretTextRe = regexp.MustCompile(`(?i)((?:PrivateKey|PresharedKey)[ \t]*[=:][ \t]*['"]?)[^ \t\r\n'"]+`)
+
+// minSecretLen is the shortest config value that is treated as a
+// secret key wherever it appears. Shorter values cannot be valid
+// keys, and replacing them would mangle unrelated text.
+const minSecretLen = 16
+
+// redactConfig replaces the values of secret keys in a config.
+func redactConfig(config string) string {
+	return secretKeyRe.ReplaceAllString(config, "${1}REDACTED")
+}
+
+// configSecrets returns the values of the secret keys in a config.
+func configSecrets(config string) []string {
+	var secrets []string
+
+	for _, match := range secretKeyRe.FindAllStringSubmatch(config, -1) {
+		value, _, _ := strings.Cut(match[2], "#")
+
+		fields := strings.Fields(value)
+		if len(fields) > 0 && len(fields[0]) >= minSecretLen {
+			secrets = append(secrets, fields[0])
+		}
+	}
+
+	return secrets
+}
+
+// redactDiagnostics removes secret keys from a file in the
+// diagnostics bundle. Besides key lines, it removes the given
+// secrets wherever they appear, since wgu's errors and logs can
+// include them without the key's name.
+func redactDiagnostics(text string, secrets []string) string {
+	for _, secret := range secrets {
+		text = strings.ReplaceAll(text, secret, "REDACTED")
+	}
+
+	text = redactConfig(text)
+
+	return secretTextRe.ReplaceAllString(text, "${1}REDACTED")
+}
+
+// wguVersionTimeout bounds how long wgu is given to report its
+// version when exporting diagnostics.
+const wguVersionTimeout = 10 * time.Second
+
+// diagnosticsResult is the outcome of exporting diagnostics.
+type diagnosticsResult struct {
+	zipPath string
+	err     error
+}
+
+// startDiagnosticsExport collects each profile's state history, logs
+// and config, and writes them to a zip file in the background with
+// secret keys redacted. The result is delivered on the event bus.
+func (s *State) startDiagnosticsExport(ctx context.Context) {
+	if s.exportingDiagnostics {
+		return
+	}
+
+	s.exportingDiagnostics = true
+	s.diagnosticsMsg = ""
+	s.diagnosticsErrMsg = ""
+
+	// Profiles are only safe to read on the UI goroutine
+	files := make(map[string]string)
+	var secrets []string
+	for _, profile := range s.profiles.profiles {
+		dir := "profiles/" + profile.name + "/"
+
+		files[dir+"config.conf"] = profile.lastReadConfig
+		files[dir+"state.txt"] = profileDiagnostics(profile)
+		files[dir+"wgu.log"] = profile.wgu.Stderr()
+
+		secrets = append(secrets, configSecrets(profile.lastReadConfig)...)
+
+		// wgu may still be running with keys that have since
+		// been removed from the config
+		if running, ok := profile.wgu.RunningConfig(); ok {
+			secrets = append(secrets, configSecrets(running)...)
+		}
+	}
+
+	numProfiles := len(s.profiles.profiles)
+
+	go func() {
+		zipPath, err := s.exportDiagnostics(ctx, files, secrets, numProfiles)
+
+		s.bus.publishDiagnostics(diagnosticsResult{
+			zipPath: zipPath,
+			err:     err,
+		})
+	}()
+}
+
+// applyDiagnosticsResult shows the outcome of exporting diagnostics.
+func (s *State) applyDiagnosticsResult(result diagnosticsResult) {
+	s.exportingDiagnostics = false
+
+	if result.err != nil {
+		s.diagnosticsMsg = ""
+		s.diagnosticsErrMsg = "failed to export diagnostics - " + result.err.Error()
+		s.errLogger.Printf("failed to export diagnostics - %v", result.err)
+		return
+	}
+
+	s.diagnosticsMsg = "diagnostics saved to " + result.zipPath
+	s.diagnosticsErrMsg = ""
+}
+
+// exportDiagnostics writes a zip file for bug reports containing
+// version and OS information, wgui's log and the profile files, with
+// secrets redacted from every file. It returns the path of the zip
+// file. It runs wgu, so it must not be called from the UI goroutine.
+func (s *State) exportDiagnostics(ctx context.Context, files map[string]string, secrets []string, numProfiles int) (string, error) {
+	dir := filepath.Join(s.wguConfDir, "diagnostics")
+
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return "", fmt.Errorf("failed to create diagnostics directory - %w", err)
+	}
+
+	zipPath := filepath.Join(dir, "wgui-diagnostics-"+time.Now().Format("20060102-150405")+".zip")
+
+	f, err := os.OpenFile(zipPath, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create diagnostics file - %w", err)
+	}
+
+	err = s.writeDiagnostics(ctx, zip.NewWriter(f), files, secrets, numProfiles)
+
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = os.Remove(zipPath)
+		return "", fmt.Errorf("failed to write diagnostics - %w", err)
+	}
+
+	return zipPath, nil
+}
+
+func (s *State) writeDiagnostics(ctx context.Context, zw *zip.Writer, files map[string]string, secrets []string, numProfiles int) error {
+	files["info.txt"] = s.diagnosticsInfo(ctx, numProfiles)
+
+	if s.logDir != "" {
+		data, err := rotlog.ReadFile(s.logFilePath(wguiLogName))
+		if err == nil {
+			files["wgui.log"] = string(data)
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write([]byte(redactDiagnostics(files[name], secrets)))
+		if err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// diagnosticsInfo describes the wgui and wgu versions and the OS
+func (s *State) diagnosticsInfo(ctx context.Context, numProfiles int) string {
+	ctx, cancelFn := context.WithTimeout(ctx, wguVersionTimeout)
+	defer cancelFn()
+
+	wguVersion, err := wguctl.GetVersion(ctx, wguctl.Config{
+		ExePath: s.wguExePath,
+	})
+	if err != nil {
+		wguVersion = "unknown - " + err.Error()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "created: %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&b, "wgui version: %s\n", version)
+	fmt.Fprintf(&b, "wgu version: %s\n", wguVersion)
+	fmt.Fprintf(&b, "wgu path: %s\n", s.wguExePath)
+	fmt.Fprintf(&b, "os: %s\n", runtime.GOOS)
+	fmt.Fprintf(&b, "arch: %s\n", runtime.GOARCH)
+	fmt.Fprintf(&b, "go version: %s\n", runtime.Version())
+	fmt.Fprintf(&b, "profiles: %d\n", numProfiles)
+
+	return b.String()
+}
+
+// profileDiagnostics describes a profile's current state and
+// state history
+func profileDiagnostics(profile profileConfig) string {
+	var b strings.Builder
+
+	state, lastErr := profile.wgu.State()
+	fmt.Fprintf(&b, "name: %s\n", profile.name)
+	fmt.Fprintf(&b, "pubkey: %s\n", profile.pubkey)
+	fmt.Fprintf(&b, "state: %s\n", state)
+	if lastErr != nil {
+		fmt.Fprintf(&b, "last error: %v\n", lastErr)
+	}
+	if profile.lastErrMsg != "" {
+		fmt.Fprintf(&b, "refresh error: %s\n", profile.lastErrMsg)
+	}
+
+	b.WriteString("\nhistory:\n")
+	for _, change := range profile.wgu.History() {
+		fmt.Fprintf(&b, "%s %s -> %s (%s)", change.At.Format(wguctl.LogFileTimeFormat),
+			change.From, change.To, change.Cause)
+		if change.Err != nil {
+			fmt.Fprintf(&b, " - %v", change.Err)
+		}
+		b.WriteByte('\n')
+	}
+
+	return b.String()
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"io"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SeungKang/wgui/internal/wguctl"
+)
+
+// shellRunner is a wguctl.Runner that runs a shell script in
+// place of wgu.
+type shellRunner string
+
+func (o shellRunner) CommandContext(ctx context.Context, _ string, _ ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, "sh", "-c", string(o))
+}
+
+// waitForDiagnostics waits for an export started by
+// startDiagnosticsExport and applies its result.
+func waitForDiagnostics(t *testing.T, s *State) diagnosticsResult {
+	t.Helper()
+
+	select {
+	case <-s.bus.wakeC():
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the export")
+	}
+
+	events := s.bus.drain()
+	if events.diagnostics == nil {
+		t.Fatal("export result was not published")
+	}
+
+	s.applyDiagnosticsResult(*events.diagnostics)
+
+	return *events.diagnostics
+}
+
+func TestState_StartDiagnosticsExport(t *testing.T) {
+	s := newTestState(t)
+	s.wguExePath = "wgu-does-not-exist"
+
+	s.startDiagnosticsExport(context.Background())
+
+	if !s.exportingDiagnostics {
+		t.Fatal("export was not started")
+	}
+
+	result := waitForDiagnostics(t, s)
+
+	if s.exportingDiagnostics || s.diagnosticsErrMsg != "" {
+		t.Fatalf("unexpected state after export: %t, %q", s.exportingDiagnostics, s.diagnosticsErrMsg)
+	}
+
+	zr, err := zip.OpenReader(result.zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+
+	if len(zr.File) != 1 || zr.File[0].Name != "info.txt" {
+		t.Fatalf("unexpected files in diagnostics: %v", zr.File)
+	}
+}
+
+func TestState_DiagnosticsRedactsWguOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake wgu is a shell script")
+	}
+
+	s := newTestState(t)
+	s.wguExePath = "wgu-does-not-exist"
+
+	fsm := wguctl.NewFsm(context.Background(), wguctl.FsmConfig{})
+	t.Cleanup(func() {
+		fsm.Destroy(context.Background())
+	})
+
+	changes, unsubscribe := fsm.Subscribe()
+	t.Cleanup(unsubscribe)
+
+	// wgu echoes the config's key without its name, and quotes
+	// a key line that is not in the config
+	script := `echo ready
+echo "ERROR: 2006/01/02 15:04:05 invalid key: ` + testPrivateKey + `" >&2
+echo "ERROR: 2006/01/02 15:04:05 failed to parse line: PresharedKey = ` + otherPrivateKey + `" >&2
+sleep 0.2
+exit 1`
+
+	err := fsm.Connect(context.Background(), wguctl.Config{
+		ConfigPath: "leaky.conf",
+		OptRunner:  shellRunner(script),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case change := <-changes:
+			done = change.To == wguctl.ErrorFsmState
+		case <-timeout:
+			t.Fatal("timed out waiting for wgu to exit")
+		}
+	}
+
+	s.profiles.profiles = append(s.profiles.profiles, profileConfig{
+		id:             "1",
+		name:           "leaky",
+		lastReadConfig: "[Interface]\nPrivateKey = " + testPrivateKey + "\n",
+		wgu:            fsm,
+	})
+
+	s.startDiagnosticsExport(context.Background())
+
+	result := waitForDiagnostics(t, s)
+	if result.err != nil {
+		t.Fatal(result.err)
+	}
+
+	zr, err := zip.OpenReader(result.zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+
+	var names []string
+	for _, file := range zr.File {
+		names = append(names, file.Name)
+
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(string(data), "REDACTED") && file.Name != "info.txt" {
+			t.Errorf("%s: expected redacted secrets - got: %q", file.Name, data)
+		}
+
+		for _, secret := range []string{testPrivateKey, otherPrivateKey} {
+			if strings.Contains(string(data), secret) {
+				t.Errorf("%s contains a secret key: %q", file.Name, data)
+			}
+		}
+	}
+
+	want := []string{
+		"info.txt",
+		"profiles/leaky/config.conf",
+		"profiles/leaky/state.txt",
+		"profiles/leaky/wgu.log",
+	}
+
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Fatalf("got files %v, want %v", names, want)
+	}
+}
+
+func TestRedactConfig(t *testing.T) {
+	const key = "dwdtCnMYpX08FsFyUbJmRd9ML4frwJkqsXf7pR25LCo="
+
+	tests := []struct {
+		config string
+		want   string
+	}{
+		{"PrivateKey = " + key + "\n", "PrivateKey = REDACTED\n"},
+		{"privatekey=" + key, "privatekey=REDACTED"},
+		{"  PRESHAREDKEY\t=\t" + key + "\n", "  PRESHAREDKEY\t=\tREDACTED\n"},
+		{"PresharedKey = " + key + " \n", "PresharedKey = REDACTED\n"},
+		{"# PrivateKey = " + key + "\n", "# PrivateKey = REDACTED\n"},
+		{";PresharedKey=" + key + "\n", ";PresharedKey=REDACTED\n"},
+		{"  #; PrivateKey = " + key + "\n", "  #; PrivateKey = REDACTED\n"},
+		{"[Interface]\r\nPrivateKey = " + key + "\r\nListenPort = 51820\r\n",
+			"[Interface]\r\nPrivateKey = REDACTED\r\nListenPort = 51820\r\n"},
+		{"[Peer]\nPublicKey = " + testPublicKey + "\n", "[Peer]\nPublicKey = " + testPublicKey + "\n"},
+		{"PrivateKey =\n", "PrivateKey =\n"},
+	}
+
+	for _, test := range tests {
+		got := redactConfig(test.config)
+		if got != test.want {
+			t.Errorf("redactConfig(%q): got %q, want %q", test.config, got, test.want)
+		}
+
+		if strings.Contains(got, key) {
+			t.Errorf("redactConfig(%q): secret key was not redacted", test.config)
+		}
+	}
+}
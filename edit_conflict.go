@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/SeungKang/wgui/internal/linediff"
+
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+)
+
+// editConflict is a config that changed on disk while it was being
+// edited.
+type editConflict struct {
+	// base is the config when editing started, yours is the
+	// editor's text, and theirs is the config on disk.
+	base   string
+	yours  string
+	theirs string
+
+	yourHunks  [][]linediff.Line
+	theirHunks [][]linediff.Line
+	merged     linediff.MergeResult
+}
+
+func newEditConflict(base string, yours string, theirs string) *editConflict {
+	baseLines := linediff.SplitLines(base)
+	yourLines := linediff.SplitLines(yours)
+	theirLines := linediff.SplitLines(theirs)
+
+	return &editConflict{
+		base:       base,
+		yours:      yours,
+		theirs:     theirs,
+		yourHunks:  linediff.Hunks(linediff.Diff(baseLines, yourLines), configDiffContext),
+		theirHunks: linediff.Hunks(linediff.Diff(baseLines, theirLines), configDiffContext),
+		merged:     linediff.Merge(baseLines, yourLines, theirLines),
+	}
+}
+
+// mergedText returns the merged config, ending with a newline
+// like the configs it was merged from.
+func (o *editConflict) mergedText() string {
+	text := strings.Join(o.merged.Lines, "\n")
+	if text != "" && (strings.HasSuffix(o.yours, "\n") || strings.HasSuffix(o.theirs, "\n")) {
+		text += "\n"
+	}
+
+	return text
+}
+
+// checkEditConflict compares the config on disk with the version the
+// editor started from. It returns a conflict if the config changed
+// on disk in the meantime, and nil if it did not or no longer exists.
+func (s *State) checkEditConflict(configPath string, yours string) (*editConflict, error) {
+	if !s.editBaseSet || configPath != s.editPath {
+		return nil, nil
+	}
+
+	onDisk, err := os.ReadFile(configPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s - %w", configPath, err)
+	}
+
+	theirs := string(onDisk)
+	if theirs == s.editBase || theirs == yours {
+		return nil, nil
+	}
+
+	return newEditConflict(s.editBase, yours, theirs), nil
+}
+
+// startEditing records the config the editor starts from, so that
+// changes made to it on disk while editing can be detected.
+func (s *State) startEditing(configPath string, base string) {
+	s.editPath = configPath
+	s.editBase = base
+	s.editBaseSet = true
+	s.editConflict = nil
+	s.restoredBackups = 0
+}
+
+// stopEditing forgets the config the editor started from, and
+// whether the user agreed to overwrite an existing profile.
+func (s *State) stopEditing() {
+	s.editPath = ""
+	s.editBase = ""
+	s.editBaseSet = false
+	s.editConflict = nil
+	s.overwritePrompt = ""
+	s.overwriteAllowed = ""
+	s.restoredBackups = 0
+}
+
+// renderEditConflict explains that the config changed on disk while
+// it was being edited, shows both sets of changes, and lets the user
+// keep their version, take the one on disk, or merge them
+func (s *State) renderEditConflict(ctx context.Context, gtx layout.Context) layout.Dimensions {
+	conflict := s.editConflict
+	if conflict == nil {
+		return D{}
+	}
+
+	keepMine := func() {
+		// Saving again overwrites the version on disk
+		s.editBase = conflict.theirs
+		s.editConflict = nil
+		s.saveProfile(ctx)
+	}
+
+	takeDisk := func() {
+		s.configEditor.SetText(conflict.theirs)
+		s.editBase = conflict.theirs
+		s.editConflict = nil
+		s.errLabel = ""
+	}
+
+	merge := func() {
+		s.configEditor.SetText(conflict.mergedText())
+		s.editBase = conflict.theirs
+		s.editConflict = nil
+		s.errLabel = ""
+
+		if conflict.merged.Conflicts > 0 {
+			s.formInfoMsg = fmt.Sprintf("Merged with %d conflict(s) - resolve the lines between %q and %q",
+				conflict.merged.Conflicts, linediff.ConflictStartMarker, linediff.ConflictEndMarker)
+		} else {
+			s.formInfoMsg = "Merged your changes with the changes on disk"
+		}
+	}
+
+	mergeLabel := "Merge"
+	if conflict.merged.Conflicts > 0 {
+		mergeLabel = fmt.Sprintf("Merge (%d conflicts)", conflict.merged.Conflicts)
+	}
+
+	return layout.Background{}.Layout(gtx,
+		func(gtx C) D {
+			paint.FillShape(gtx.Ops, SelectedBg, clip.Rect{Max: gtx.Constraints.Min}.Op())
+			return D{Size: gtx.Constraints.Min}
+		},
+		func(gtx C) D {
+			return layout.UniformInset(unit.Dp(8)).Layout(gtx, func(gtx C) D {
+				return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+					layout.Rigid(func(gtx C) D {
+						label := material.Label(s.theme, 14, "The config changed on disk since you started editing it")
+						label.Color = HighlightColor
+						return label.Layout(gtx)
+					}),
+					layout.Rigid(func(gtx C) D {
+						return s.renderConflictSide(gtx, "Your changes", conflict.yourHunks)
+					}),
+					layout.Rigid(func(gtx C) D {
+						return s.renderConflictSide(gtx, "Changes on disk", conflict.theirHunks)
+					}),
+					layout.Rigid(func(gtx C) D {
+						return layout.Inset{Top: unit.Dp(8)}.Layout(gtx, func(gtx C) D {
+							return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+								layout.Rigid(func(gtx C) D {
+									return s.renderButton(gtx, "Keep Mine", PurpleColor, s.keepMineButton, keepMine)
+								}),
+								layout.Rigid(func(gtx C) D {
+									return layout.Inset{Left: unit.Dp(12)}.Layout(gtx, func(gtx C) D {
+										return s.renderButton(gtx, "Take Disk", GreyColor, s.takeDiskButton, takeDisk)
+									})
+								}),
+								layout.Rigid(func(gtx C) D {
+									return layout.Inset{Left: unit.Dp(12)}.Layout(gtx, func(gtx C) D {
+										return s.renderButton(gtx, mergeLabel, GreyColor, s.mergeButton, merge)
+									})
+								}),
+							)
+						})
+					}),
+				)
+			})
+		},
+	)
+}
+
+// renderConflictSide shows the changes one side of a conflict made
+func (s *State) renderConflictSide(gtx layout.Context, title string, hunks [][]linediff.Line) layout.Dimensions {
+	children := []layout.FlexChild{
+		layout.Rigid(func(gtx C) D {
+			label := material.Label(s.theme, 12, title)
+			label.Color = WhiteColor
+			return layout.Inset{Top: unit.Dp(8), Bottom: unit.Dp(2)}.Layout(gtx, label.Layout)
+		}),
+	}
+
+	if len(hunks) == 0 {
+		children = append(children, layout.Rigid(func(gtx C) D {
+			return s.logText("  (none)").Layout(gtx)
+		}))
+	}
+
+	for i, hunk := range hunks {
+		if i > 0 {
+			children = append(children, layout.Rigid(func(gtx C) D {
+				return s.logText("  ...").Layout(gtx)
+			}))
+		}
+
+		for _, line := range hunk {
+			children = append(children, layout.Rigid(func(gtx C) D {
+				label := s.logText(line.String())
+
+				switch line.Op {
+				case linediff.Insert:
+					label.Color = DiffAddColor
+				case linediff.Delete:
+					label.Color = LogErrorColor
+				}
+
+				return label.Layout(gtx)
+			}))
+		}
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckEditConflict(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "test.conf")
+
+	s := &State{}
+	s.startEditing(configPath, "a\nb\nc\n")
+
+	// A missing file is not a conflict
+	conflict, err := s.checkEditConflict(configPath, "a\nB\nc\n")
+	if err != nil || conflict != nil {
+		t.Fatalf("got %v, %v", conflict, err)
+	}
+
+	err = os.WriteFile(configPath, []byte("a\nb\nc\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conflict, err = s.checkEditConflict(configPath, "a\nB\nc\n")
+	if err != nil || conflict != nil {
+		t.Fatalf("unchanged file: got %v, %v", conflict, err)
+	}
+
+	err = os.WriteFile(configPath, []byte("a\nb\nc\nd\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conflict, err = s.checkEditConflict(configPath, "a\nB\nc\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if conflict == nil {
+		t.Fatal("expected a conflict")
+	}
+
+	if got, want := conflict.mergedText(), "a\nB\nc\nd\n"; got != want {
+		t.Fatalf("merged: got %q, want %q", got, want)
+	}
+
+	// Saving a different profile is not a conflict
+	conflict, err = s.checkEditConflict(filepath.Join(filepath.Dir(configPath), "other.conf"), "x")
+	if err != nil || conflict != nil {
+		t.Fatalf("other profile: got %v, %v", conflict, err)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/SeungKang/wgui/internal/dirwatch"
+	"github.com/SeungKang/wgui/internal/wguctl"
+)
+
+// eventBus carries notifications from background goroutines to the UI
+// goroutine. Publishing never blocks: notifications are merged into a
+// pending set and the UI goroutine is woken up at most once until it
+// drains them, so a burst of log lines results in a single redraw.
+type eventBus struct {
+	mu      sync.Mutex
+	pending busEvents
+	wake    chan struct{}
+}
+
+// busEvents are the notifications merged since the last drain,
+// keyed by profile id.
+type busEvents struct {
+	// newLogs has the profiles that received new log lines.
+	newLogs map[string]struct{}
+
+	// stateChanges has the latest state change of each profile.
+	stateChanges map[string]wguctl.StateChange
+
+	// newStatus has the profiles whose wgu reported a new status.
+	newStatus map[string]struct{}
+
+	// handshakes has the time of the latest completed handshake
+	// of each profile.
+	handshakes map[string]time.Time
+
+	// refreshes has the latest refresh result of each profile.
+	refreshes map[string]refreshResult
+
+	// configChanges has the changes made to the config directory.
+	configChanges dirwatch.Changes
+
+	// diagnostics has the result of exporting diagnostics, if
+	// an export finished.
+	diagnostics *diagnosticsResult
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// wakeC returns a channel that receives a value when there are
+// pending notifications to drain.
+func (o *eventBus) wakeC() <-chan struct{} {
+	return o.wake
+}
+
+// drain returns and clears the pending notifications.
+func (o *eventBus) drain() busEvents {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	events := o.pending
+	o.pending = busEvents{}
+
+	return events
+}
+
+// affects reports whether any notification is about the profile
+// with the given id.
+func (o busEvents) affects(id string) bool {
+	_, hasLogs := o.newLogs[id]
+	_, hasState := o.stateChanges[id]
+	_, hasStatus := o.newStatus[id]
+	_, hasHandshake := o.handshakes[id]
+	_, hasRefresh := o.refreshes[id]
+
+	return hasLogs || hasState || hasStatus || hasHandshake || hasRefresh
+}
+
+func (o *eventBus) publishNewLogs(id string) {
+	o.publish(func(pending *busEvents) {
+		if pending.newLogs == nil {
+			pending.newLogs = make(map[string]struct{})
+		}
+
+		pending.newLogs[id] = struct{}{}
+	})
+}
+
+func (o *eventBus) publishNewStatus(id string) {
+	o.publish(func(pending *busEvents) {
+		if pending.newStatus == nil {
+			pending.newStatus = make(map[string]struct{})
+		}
+
+		pending.newStatus[id] = struct{}{}
+	})
+}
+
+func (o *eventBus) publishStateChange(id string, change wguctl.StateChange) {
+	o.publish(func(pending *busEvents) {
+		if pending.stateChanges == nil {
+			pending.stateChanges = make(map[string]wguctl.StateChange)
+		}
+
+		pending.stateChanges[id] = change
+	})
+}
+
+func (o *eventBus) publishHandshake(id string, at time.Time) {
+	o.publish(func(pending *busEvents) {
+		if pending.handshakes == nil {
+			pending.handshakes = make(map[string]time.Time)
+		}
+
+		pending.handshakes[id] = at
+	})
+}
+
+func (o *eventBus) publishRefresh(id string, result refreshResult) {
+	o.publish(func(pending *busEvents) {
+		if pending.refreshes == nil {
+			pending.refreshes = make(map[string]refreshResult)
+		}
+
+		pending.refreshes[id] = result
+	})
+}
+
+func (o *eventBus) publishConfigChanges(changes dirwatch.Changes) {
+	o.publish(func(pending *busEvents) {
+		pending.configChanges.Merge(changes)
+	})
+}
+
+func (o *eventBus) publishDiagnostics(result diagnosticsResult) {
+	o.publish(func(pending *busEvents) {
+		pending.diagnostics = &result
+	})
+}
+
+func (o *eventBus) publish(merge func(pending *busEvents)) {
+	o.mu.Lock()
+	merge(&o.pending)
+	o.mu.Unlock()
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+		// The UI goroutine has already been woken up
+	}
+}
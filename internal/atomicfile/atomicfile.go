@@ -0,0 +1,65 @@
+// Package atomicfile writes files so that readers see either their
+// old or their new contents, even if writing is interrupted by a
+// crash or a full disk.
+package atomicfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WriteFile writes data to a temporary file next to path, syncs it
+// to disk and renames it over path. The temporary file is hidden and
+// removed if anything fails, leaving the file at path untouched.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file - %w", err)
+	}
+
+	tmpPath := tmp.Name()
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Chmod(tmpPath, perm)
+	}
+
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to write temporary file - %w", err)
+	}
+
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace %s - %w", path, err)
+	}
+
+	syncDir(dir)
+
+	return nil
+}
+
+// syncDir makes a rename in dir durable. This is best effort, since
+// directories cannot be synced on every platform.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+
+	_ = d.Sync()
+	_ = d.Close()
+}
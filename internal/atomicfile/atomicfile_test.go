@@ -0,0 +1,46 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.conf")
+
+	for _, contents := range []string{"first\n", "second\n"} {
+		err := WriteFile(path, []byte(contents), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != contents {
+			t.Fatalf("got %q, want %q", data, contents)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("temporary files were left behind: %v", entries)
+	}
+}
+
+func TestWriteFile_MissingDir(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "test.conf")
+
+	err := WriteFile(path, []byte("data"), 0600)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,200 @@
+// Package dirwatch watches a directory for changes to the files
+// matching a pattern by polling it.
+//
+// Polling is used instead of OS notifications because it works the
+// same everywhere, including network drives, and the directories it
+// is meant for are small.
+package dirwatch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Rename is a file that was renamed between two polls.
+type Rename struct {
+	From string
+	To   string
+}
+
+// Changes describes how the matching files changed between two polls.
+// All paths include the watched directory.
+type Changes struct {
+	Added    []string
+	Removed  []string
+	Modified []string
+	Renamed  []Rename
+}
+
+// IsEmpty reports whether nothing changed.
+func (o Changes) IsEmpty() bool {
+	return len(o.Added) == 0 && len(o.Removed) == 0 &&
+		len(o.Modified) == 0 && len(o.Renamed) == 0
+}
+
+// Merge appends other's changes to o's.
+func (o *Changes) Merge(other Changes) {
+	o.Added = append(o.Added, other.Added...)
+	o.Removed = append(o.Removed, other.Removed...)
+	o.Modified = append(o.Modified, other.Modified...)
+	o.Renamed = append(o.Renamed, other.Renamed...)
+}
+
+type fileInfo struct {
+	modTime time.Time
+	size    int64
+}
+
+// Watcher polls a directory for changes. It is not safe for
+// concurrent use.
+type Watcher struct {
+	dir      string
+	pattern  string
+	snapshot map[string]fileInfo
+}
+
+// New creates a Watcher for the files in dir that match pattern,
+// as in filepath.Match. Files that exist when New is called are
+// not reported as added.
+func New(dir string, pattern string) (*Watcher, error) {
+	_, err := filepath.Match(pattern, "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q - %w", pattern, err)
+	}
+
+	w := &Watcher{
+		dir:     dir,
+		pattern: pattern,
+	}
+
+	w.snapshot, err = w.scan()
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Poll returns the changes since the previous poll.
+//
+// A file that disappears while another file with the same size and
+// modification time appears is reported as renamed, since renaming
+// a file keeps its modification time.
+func (o *Watcher) Poll() (Changes, error) {
+	current, err := o.scan()
+	if err != nil {
+		return Changes{}, err
+	}
+
+	var changes Changes
+	var added []string
+	var removed []string
+
+	for path, info := range current {
+		prev, existed := o.snapshot[path]
+		switch {
+		case !existed:
+			added = append(added, path)
+		case !prev.modTime.Equal(info.modTime) || prev.size != info.size:
+			changes.Modified = append(changes.Modified, path)
+		}
+	}
+
+	for path := range o.snapshot {
+		if _, exists := current[path]; !exists {
+			removed = append(removed, path)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(changes.Modified)
+
+	for _, from := range removed {
+		prev := o.snapshot[from]
+
+		renamed := false
+		for i, to := range added {
+			info := current[to]
+			if info.size == prev.size && info.modTime.Equal(prev.modTime) {
+				changes.Renamed = append(changes.Renamed, Rename{From: from, To: to})
+				added = append(added[:i], added[i+1:]...)
+				renamed = true
+				break
+			}
+		}
+
+		if !renamed {
+			changes.Removed = append(changes.Removed, from)
+		}
+	}
+
+	if len(added) > 0 {
+		changes.Added = added
+	}
+
+	o.snapshot = current
+
+	return changes, nil
+}
+
+// Run polls the directory every interval until ctx is done, calling
+// onChange when something changed and onErr when polling fails.
+func (o *Watcher) Run(ctx context.Context, interval time.Duration, onChange func(Changes), onErr func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changes, err := o.Poll()
+			if err != nil {
+				onErr(err)
+				continue
+			}
+
+			if !changes.IsEmpty() {
+				onChange(changes)
+			}
+		}
+	}
+}
+
+func (o *Watcher) scan() (map[string]fileInfo, error) {
+	entries, err := os.ReadDir(o.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s - %w", o.dir, err)
+	}
+
+	snapshot := make(map[string]fileInfo, len(entries))
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		matched, _ := filepath.Match(o.pattern, entry.Name())
+		if !matched {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			// The file was removed after the directory was read
+			continue
+		}
+
+		snapshot[filepath.Join(o.dir, entry.Name())] = fileInfo{
+			modTime: info.ModTime(),
+			size:    info.Size(),
+		}
+	}
+
+	return snapshot, nil
+}
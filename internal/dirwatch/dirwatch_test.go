@@ -0,0 +1,153 @@
+package dirwatch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path string, content string, modTime time.Time) {
+	t.Helper()
+
+	err := os.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Chtimes(path, modTime, modTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func poll(t *testing.T, w *Watcher) Changes {
+	t.Helper()
+
+	changes, err := w.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return changes
+}
+
+func TestWatcher_Poll(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	a := filepath.Join(dir, "a.conf")
+	b := filepath.Join(dir, "b.conf")
+	c := filepath.Join(dir, "c.conf")
+
+	writeFile(t, a, "a", modTime)
+	writeFile(t, filepath.Join(dir, "ignored.txt"), "x", modTime)
+
+	w, err := New(dir, "*.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if changes := poll(t, w); !changes.IsEmpty() {
+		t.Fatalf("existing files were reported: %+v", changes)
+	}
+
+	writeFile(t, b, "bb", modTime)
+
+	if got, want := poll(t, w), (Changes{Added: []string{b}}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	writeFile(t, a, "a2", modTime.Add(time.Minute))
+
+	if got, want := poll(t, w), (Changes{Modified: []string{a}}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	err = os.Rename(b, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := poll(t, w), (Changes{Renamed: []Rename{{From: b, To: c}}}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	err = os.Remove(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := poll(t, w), (Changes{Removed: []string{a}}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestWatcher_RemoveAndAddIsNotRename(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	a := filepath.Join(dir, "a.conf")
+	b := filepath.Join(dir, "b.conf")
+
+	writeFile(t, a, "a", modTime)
+
+	w, err := New(dir, "*.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Remove(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, b, "different", modTime.Add(time.Minute))
+
+	got := poll(t, w)
+	want := Changes{Added: []string{b}, Removed: []string{a}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestWatcher_Run(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := New(dir, "*.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	changesCh := make(chan Changes, 1)
+
+	go w.Run(ctx, 10*time.Millisecond, func(changes Changes) {
+		changesCh <- changes
+	}, func(err error) {
+		t.Error(err)
+	})
+
+	path := filepath.Join(dir, "new.conf")
+	writeFile(t, path, "new", time.Now())
+
+	select {
+	case changes := <-changesCh:
+		if len(changes.Added) != 1 || changes.Added[0] != path {
+			t.Fatalf("unexpected changes: %+v", changes)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for changes")
+	}
+}
+
+func TestNew_MissingDir(t *testing.T) {
+	_, err := New(filepath.Join(t.TempDir(), "missing"), "*.conf")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
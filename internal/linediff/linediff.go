@@ -0,0 +1,170 @@
+// Package linediff compares texts line by line.
+package linediff
+
+import (
+	"strings"
+)
+
+// Op is what happened to a line.
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// Line is a line of a diff.
+type Line struct {
+	Op   Op
+	Text string
+
+	// OldNum and NewNum are the 1-based line numbers in the old
+	// and new text, or 0 if the line is not in that text.
+	OldNum int
+	NewNum int
+}
+
+// String returns the line prefixed like in a unified diff.
+func (o Line) String() string {
+	switch o.Op {
+	case Insert:
+		return "+ " + o.Text
+	case Delete:
+		return "- " + o.Text
+	default:
+		return "  " + o.Text
+	}
+}
+
+// SplitLines splits text into lines without their line endings.
+// A trailing line ending does not produce an empty last line.
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// Diff returns the lines of a and b in order, marking the lines that
+// were deleted from a and inserted into b. Deletions come before
+// insertions where lines were replaced.
+func Diff(a []string, b []string) []Line {
+	// Lines at the start and end are often the same,
+	// and do not need to go through the LCS table
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var lines []Line
+
+	for i := 0; i < prefix; i++ {
+		lines = append(lines, Line{Op: Equal, Text: a[i], OldNum: i + 1, NewNum: i + 1})
+	}
+
+	lines = append(lines, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], prefix, prefix)...)
+
+	for i := 0; i < suffix; i++ {
+		oldIndex := len(a) - suffix + i
+		newIndex := len(b) - suffix + i
+		lines = append(lines, Line{Op: Equal, Text: a[oldIndex], OldNum: oldIndex + 1, NewNum: newIndex + 1})
+	}
+
+	return lines
+}
+
+// diffMiddle diffs a and b using a longest common subsequence table.
+// The offsets are added to the line numbers.
+func diffMiddle(a []string, b []string, oldOffset int, newOffset int) []Line {
+	// lcs[i][j] is the length of the LCS of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []Line
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, Line{Op: Equal, Text: a[i], OldNum: oldOffset + i + 1, NewNum: newOffset + j + 1})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, Line{Op: Delete, Text: a[i], OldNum: oldOffset + i + 1})
+			i++
+		default:
+			lines = append(lines, Line{Op: Insert, Text: b[j], NewNum: newOffset + j + 1})
+			j++
+		}
+	}
+
+	return lines
+}
+
+// HasChanges reports whether any line was inserted or deleted.
+func HasChanges(lines []Line) bool {
+	for _, line := range lines {
+		if line.Op != Equal {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Hunks groups the changed lines of a diff along with up to context
+// unchanged lines around them. Unchanged lines far from any change
+// are left out.
+func Hunks(lines []Line, context int) [][]Line {
+	var hunks [][]Line
+
+	start, end := -1, -1
+
+	for i, line := range lines {
+		if line.Op == Equal {
+			continue
+		}
+
+		from := max(i-context, 0)
+		to := min(i+context+1, len(lines))
+
+		if start >= 0 && from <= end {
+			end = to
+			continue
+		}
+
+		if start >= 0 {
+			hunks = append(hunks, lines[start:end])
+		}
+
+		start, end = from, to
+	}
+
+	if start >= 0 {
+		hunks = append(hunks, lines[start:end])
+	}
+
+	return hunks
+}
@@ -0,0 +1,101 @@
+package linediff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func diffString(lines []Line) string {
+	var b strings.Builder
+
+	for _, line := range lines {
+		b.WriteString(line.String())
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+func TestSplitLines(t *testing.T) {
+	tests := map[string][]string{
+		"":           nil,
+		"a":          {"a"},
+		"a\n":        {"a"},
+		"a\r\nb\r\n": {"a", "b"},
+		"a\n\nb":     {"a", "", "b"},
+	}
+
+	for text, want := range tests {
+		if got := SplitLines(text); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%q: got %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := SplitLines("[Interface]\nPrivateKey = a\nListenPort = 1\n\n[Peer]\nPublicKey = b\n")
+	b := SplitLines("[Interface]\nPrivateKey = a\nListenPort = 2\n\n[Peer]\nPublicKey = b\nEndpoint = x:1\n")
+
+	want := `  [Interface]
+  PrivateKey = a
+- ListenPort = 1
++ ListenPort = 2
+  
+  [Peer]
+  PublicKey = b
++ Endpoint = x:1
+`
+
+	lines := Diff(a, b)
+	if got := diffString(lines); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if lines[3].NewNum != 3 || lines[2].OldNum != 3 || lines[7].NewNum != 7 {
+		t.Fatalf("unexpected line numbers: %+v", lines)
+	}
+
+	if !HasChanges(lines) {
+		t.Fatal("expected changes")
+	}
+}
+
+func TestDiff_Equal(t *testing.T) {
+	a := SplitLines("a\nb\nc")
+
+	if HasChanges(Diff(a, a)) {
+		t.Fatal("expected no changes")
+	}
+}
+
+func TestDiff_Empty(t *testing.T) {
+	got := diffString(Diff(nil, []string{"a"}))
+	if got != "+ a\n" {
+		t.Fatalf("got %q", got)
+	}
+
+	got = diffString(Diff([]string{"a"}, nil))
+	if got != "- a\n" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestHunks(t *testing.T) {
+	a := SplitLines("1\n2\n3\n4\n5\n6\n7\n8\n9\n10")
+	b := SplitLines("1\nX\n3\n4\n5\n6\n7\n8\nY\n10")
+
+	hunks := Hunks(Diff(a, b), 1)
+	if len(hunks) != 2 {
+		t.Fatalf("got %d hunks, want 2", len(hunks))
+	}
+
+	if got := diffString(hunks[0]); got != "  1\n- 2\n+ X\n  3\n" {
+		t.Fatalf("unexpected first hunk:\n%s", got)
+	}
+
+	// Enough context merges the hunks
+	if hunks := Hunks(Diff(a, b), 3); len(hunks) != 1 {
+		t.Fatalf("got %d hunks, want 1", len(hunks))
+	}
+}
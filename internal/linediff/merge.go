@@ -0,0 +1,172 @@
+package linediff
+
+// Conflict markers written around lines that both sides
+// changed differently.
+const (
+	ConflictStartMarker = "<<<<<<< yours"
+	ConflictSepMarker   = "======="
+	ConflictEndMarker   = ">>>>>>> theirs"
+)
+
+// edit replaces base[start:end] with lines.
+type edit struct {
+	start int
+	end   int
+	lines []string
+}
+
+// edits converts a diff against base into the edits that turn base
+// into the other text.
+func edits(diff []Line) []edit {
+	var result []edit
+	var current *edit
+
+	baseIndex := 0
+
+	for _, line := range diff {
+		if line.Op == Equal {
+			if current != nil {
+				result = append(result, *current)
+				current = nil
+			}
+
+			baseIndex++
+			continue
+		}
+
+		if current == nil {
+			current = &edit{start: baseIndex, end: baseIndex}
+		}
+
+		if line.Op == Delete {
+			baseIndex++
+			current.end = baseIndex
+		} else {
+			current.lines = append(current.lines, line.Text)
+		}
+	}
+
+	if current != nil {
+		result = append(result, *current)
+	}
+
+	return result
+}
+
+// MergeResult is the outcome of a three-way merge.
+type MergeResult struct {
+	Lines []string
+
+	// Conflicts is the number of places where both sides changed
+	// the same lines differently. Each is surrounded by conflict
+	// markers in Lines.
+	Conflicts int
+}
+
+// Merge combines the changes made to base in yours and in theirs.
+// Changes made by only one side are kept. Changes made by both
+// sides to overlapping lines are kept if they are the same, and
+// marked as a conflict otherwise.
+func Merge(base []string, yours []string, theirs []string) MergeResult {
+	yourEdits := edits(Diff(base, yours))
+	theirEdits := edits(Diff(base, theirs))
+
+	var result MergeResult
+
+	baseIndex := 0
+	y, t := 0, 0
+
+	for y < len(yourEdits) || t < len(theirEdits) {
+		// Start a group with the edit that comes first, and
+		// grow it while edits from either side overlap it
+		var start, end int
+		if t == len(theirEdits) || (y < len(yourEdits) && yourEdits[y].start <= theirEdits[t].start) {
+			start, end = yourEdits[y].start, yourEdits[y].end
+		} else {
+			start, end = theirEdits[t].start, theirEdits[t].end
+		}
+
+		yStart, tStart := y, t
+
+		for {
+			grew := false
+
+			for y < len(yourEdits) && overlaps(yourEdits[y], start, end) {
+				end = max(end, yourEdits[y].end)
+				y++
+				grew = true
+			}
+
+			for t < len(theirEdits) && overlaps(theirEdits[t], start, end) {
+				end = max(end, theirEdits[t].end)
+				t++
+				grew = true
+			}
+
+			if !grew {
+				break
+			}
+		}
+
+		result.Lines = append(result.Lines, base[baseIndex:start]...)
+		baseIndex = end
+
+		yourLines := apply(base, start, end, yourEdits[yStart:y])
+		theirLines := apply(base, start, end, theirEdits[tStart:t])
+
+		switch {
+		case y == yStart:
+			result.Lines = append(result.Lines, theirLines...)
+		case t == tStart:
+			result.Lines = append(result.Lines, yourLines...)
+		case equalLines(yourLines, theirLines):
+			result.Lines = append(result.Lines, yourLines...)
+		default:
+			result.Conflicts++
+			result.Lines = append(result.Lines, ConflictStartMarker)
+			result.Lines = append(result.Lines, yourLines...)
+			result.Lines = append(result.Lines, ConflictSepMarker)
+			result.Lines = append(result.Lines, theirLines...)
+			result.Lines = append(result.Lines, ConflictEndMarker)
+		}
+	}
+
+	result.Lines = append(result.Lines, base[baseIndex:]...)
+
+	return result
+}
+
+// overlaps reports whether e touches the lines base[start:end].
+// Insertions at the same place as another edit overlap it.
+func overlaps(e edit, start int, end int) bool {
+	return e.start < end && start < e.end || e.start == start || e.start == end && e.start == e.end
+}
+
+// apply returns base[start:end] with the edits applied. The edits
+// must be sorted and within the range.
+func apply(base []string, start int, end int, edits []edit) []string {
+	var lines []string
+
+	i := start
+	for _, e := range edits {
+		lines = append(lines, base[i:e.start]...)
+		lines = append(lines, e.lines...)
+		i = e.end
+	}
+
+	return append(lines, base[i:end]...)
+}
+
+func equalLines(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,78 @@
+package linediff
+
+import (
+	"strings"
+	"testing"
+)
+
+func merge(base string, yours string, theirs string) (string, int) {
+	result := Merge(SplitLines(base), SplitLines(yours), SplitLines(theirs))
+
+	return strings.Join(result.Lines, "\n"), result.Conflicts
+}
+
+func TestMerge_NonOverlapping(t *testing.T) {
+	base := "a\nb\nc\nd\ne"
+	yours := "a\nB\nc\nd\ne"
+	theirs := "a\nb\nc\nd\nE\nf"
+
+	got, conflicts := merge(base, yours, theirs)
+	if conflicts != 0 {
+		t.Fatalf("got %d conflicts", conflicts)
+	}
+
+	if want := "a\nB\nc\nd\nE\nf"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestMerge_SameChange(t *testing.T) {
+	got, conflicts := merge("a\nb\nc", "a\nX\nc", "a\nX\nc")
+	if conflicts != 0 || got != "a\nX\nc" {
+		t.Fatalf("got %q with %d conflicts", got, conflicts)
+	}
+}
+
+func TestMerge_Conflict(t *testing.T) {
+	got, conflicts := merge("a\nb\nc", "a\nyours\nc", "a\ntheirs\nc")
+	if conflicts != 1 {
+		t.Fatalf("got %d conflicts, want 1", conflicts)
+	}
+
+	want := "a\n" + ConflictStartMarker + "\nyours\n" + ConflictSepMarker + "\ntheirs\n" + ConflictEndMarker + "\nc"
+	if got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMerge_InsertsAtSamePlace(t *testing.T) {
+	_, conflicts := merge("a\nb", "a\nx\nb", "a\ny\nb")
+	if conflicts != 1 {
+		t.Fatalf("got %d conflicts, want 1", conflicts)
+	}
+}
+
+func TestMerge_OneSideUnchanged(t *testing.T) {
+	for _, test := range []struct {
+		yours  string
+		theirs string
+		want   string
+	}{
+		{yours: "a\nb", theirs: "a\nb\nc", want: "a\nb\nc"},
+		{yours: "x\na\nb", theirs: "a\nb", want: "x\na\nb"},
+		{yours: "b", theirs: "a\nb", want: "b"},
+	} {
+		got, conflicts := merge("a\nb", test.yours, test.theirs)
+		if conflicts != 0 || got != test.want {
+			t.Fatalf("yours %q, theirs %q: got %q with %d conflicts, want %q",
+				test.yours, test.theirs, got, conflicts, test.want)
+		}
+	}
+}
+
+func TestMerge_DeleteAndEdit(t *testing.T) {
+	_, conflicts := merge("a\nb\nc", "a\nc", "a\nB\nc")
+	if conflicts != 1 {
+		t.Fatalf("got %d conflicts, want 1", conflicts)
+	}
+}
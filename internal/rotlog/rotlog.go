@@ -0,0 +1,391 @@
+// Package rotlog implements a log file writer that rotates the file
+// once it grows too large, keeping a limited number of compressed
+// older files next to it.
+package rotlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// Writer writes to a log file, rotating it to <path>.1.gz once it
+// would grow past MaxSize. Older files are shifted to <path>.2.gz and
+// so on, keeping at most MaxFiles rotated files.
+type Writer struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// Open opens or creates the log file at path for appending.
+func Open(path string, maxSize int64, maxFiles int) (*Writer, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log directory - %w", err)
+	}
+
+	w := &Writer{
+		path:     path,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+	}
+
+	err = w.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (o *Writer) open() error {
+	f, err := os.OpenFile(o.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open log file - %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to stat log file - %w", err)
+	}
+
+	o.file = f
+	o.size = info.Size()
+
+	return nil
+}
+
+// Write appends p to the log file, rotating it first if it would
+// grow too large. If rotating fails, p is still written to the
+// current file and the rotation error is returned.
+func (o *Writer) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	var rotateErr error
+	if o.size > 0 && o.size+int64(len(p)) > o.maxSize {
+		rotateErr = o.rotate()
+		if o.file == nil {
+			return 0, rotateErr
+		}
+	}
+
+	n, err := o.file.Write(p)
+	o.size += int64(n)
+
+	if err == nil {
+		err = rotateErr
+	}
+
+	return n, err
+}
+
+func (o *Writer) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.file == nil {
+		return nil
+	}
+
+	err := o.file.Close()
+	o.file = nil
+
+	return err
+}
+
+// Rename moves the log file and its rotated files to newPath and
+// continues writing to it. Log files already at newPath are kept as
+// files older than the moved ones, with the oldest removed so that
+// there are at most MaxFiles rotated files. The file is closed while
+// it is moved, since open files cannot be renamed on Windows.
+func (o *Writer) Rename(newPath string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if newPath == o.path {
+		return nil
+	}
+
+	if o.file != nil {
+		err := o.file.Close()
+		o.file = nil
+		if err != nil {
+			_ = o.open()
+			return fmt.Errorf("failed to close log file - %w", err)
+		}
+	}
+
+	aside, err := moveAside(newPath, o.maxFiles)
+	if err != nil {
+		_ = o.open()
+		return err
+	}
+
+	oldPath := o.path
+
+	err = renameIfExists(oldPath, newPath)
+	if err != nil {
+		// Keep writing to the old path rather than not at all
+		_ = o.open()
+		return err
+	}
+
+	o.path = newPath
+
+	next := 1
+	for i := 1; i <= o.maxFiles && err == nil; i++ {
+		src := rotatedPath(oldPath, i)
+		if _, statErr := os.Stat(src); statErr != nil {
+			continue
+		}
+
+		err = renameIfExists(src, rotatedPath(newPath, next))
+		next++
+	}
+
+	if err == nil && aside != nil {
+		err = aside.restore(newPath, next, o.maxFiles)
+	}
+
+	openErr := o.open()
+	if err != nil {
+		return err
+	}
+
+	return openErr
+}
+
+// asideFiles is log files that were moved into a temporary
+// directory to make room for other log files.
+type asideFiles struct {
+	dir string
+
+	// current is the path of the current file, if it existed.
+	current string
+
+	// rotated is the paths of the rotated files, newest first.
+	rotated []string
+}
+
+// moveAside moves the log files at path into a temporary directory
+// next to them. It returns nil if there are no log files at path.
+func moveAside(path string, maxFiles int) (*asideFiles, error) {
+	existing := Files(path, maxFiles)
+	if len(existing) == 0 {
+		return nil, nil
+	}
+
+	dir, err := os.MkdirTemp(filepath.Dir(path), filepath.Base(path)+".old-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create directory for existing log files - %w", err)
+	}
+
+	aside := &asideFiles{
+		dir: dir,
+	}
+
+	// Files returns the oldest first
+	for i := len(existing) - 1; i >= 0; i-- {
+		file := existing[i]
+		dst := filepath.Join(dir, filepath.Base(file))
+
+		err = os.Rename(file, dst)
+		if err != nil {
+			return nil, fmt.Errorf("failed to move existing log file aside - %w", err)
+		}
+
+		if file == path {
+			aside.current = dst
+		} else {
+			aside.rotated = append(aside.rotated, dst)
+		}
+	}
+
+	return aside, nil
+}
+
+// restore moves the files back as the rotated files of path,
+// starting at <path>.<next>.gz. The current file is compressed.
+// Files that do not fit in maxFiles are removed, along with the
+// temporary directory. The directory is kept if a file cannot be
+// moved back, so that it is not lost.
+func (o *asideFiles) restore(path string, next int, maxFiles int) error {
+	if o.current != "" && next <= maxFiles {
+		err := compressFile(o.current, rotatedPath(path, next))
+		if err != nil {
+			return fmt.Errorf("failed to keep existing log file %s - %w", o.current, err)
+		}
+
+		next++
+	}
+
+	for _, file := range o.rotated {
+		if next > maxFiles {
+			break
+		}
+
+		err := os.Rename(file, rotatedPath(path, next))
+		if err != nil {
+			return fmt.Errorf("failed to keep existing log file %s - %w", file, err)
+		}
+
+		next++
+	}
+
+	err := os.RemoveAll(o.dir)
+	if err != nil {
+		return fmt.Errorf("failed to remove existing log files - %w", err)
+	}
+
+	return nil
+}
+
+// renameIfExists renames src to dst if src exists.
+func renameIfExists(src string, dst string) error {
+	err := os.Rename(src, dst)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to rename log file - %w", err)
+	}
+
+	return nil
+}
+
+// rotate compresses the current file into <path>.1.gz, shifting
+// older files up by one, and starts a new empty file. The current
+// file is reopened even if rotating fails, so that logging does not
+// stop for the rest of the session.
+func (o *Writer) rotate() error {
+	err := o.file.Close()
+	o.file = nil
+	if err != nil {
+		err = fmt.Errorf("failed to close log file - %w", err)
+	} else {
+		err = o.shiftFiles()
+	}
+
+	openErr := o.open()
+	if err != nil {
+		return err
+	}
+
+	return openErr
+}
+
+// shiftFiles moves the closed current file to <path>.1.gz,
+// shifting older files up by one and removing the oldest.
+func (o *Writer) shiftFiles() error {
+	_ = os.Remove(rotatedPath(o.path, o.maxFiles))
+
+	for i := o.maxFiles - 1; i >= 1; i-- {
+		err := os.Rename(rotatedPath(o.path, i), rotatedPath(o.path, i+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to shift rotated log file - %w", err)
+		}
+	}
+
+	if o.maxFiles > 0 {
+		err := compressFile(o.path, rotatedPath(o.path, 1))
+		if err != nil {
+			return err
+		}
+	}
+
+	err := os.Remove(o.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove rotated log file - %w", err)
+	}
+
+	return nil
+}
+
+func compressFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open log file for compression - %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create compressed log file - %w", err)
+	}
+
+	gz := gzip.NewWriter(out)
+
+	_, err = io.Copy(gz, in)
+	if err == nil {
+		err = gz.Close()
+	}
+
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = os.Remove(dst)
+		return fmt.Errorf("failed to compress log file - %w", err)
+	}
+
+	return nil
+}
+
+func rotatedPath(path string, i int) string {
+	return path + "." + strconv.Itoa(i) + ".gz"
+}
+
+// Files returns the paths of the existing log files for path,
+// oldest first, ending with the current file.
+func Files(path string, maxFiles int) []string {
+	var paths []string
+
+	for i := maxFiles; i >= 1; i-- {
+		rotated := rotatedPath(path, i)
+		if _, err := os.Stat(rotated); err == nil {
+			paths = append(paths, rotated)
+		}
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		paths = append(paths, path)
+	}
+
+	return paths
+}
+
+// ReadFile reads a log file, decompressing it if it was rotated.
+func ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if filepath.Ext(path) != ".gz" {
+		return data, nil
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compressed log file %s - %w", path, err)
+	}
+	defer gz.Close()
+
+	return io.ReadAll(gz)
+}
@@ -0,0 +1,299 @@
+package rotlog
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func writeLines(t *testing.T, w *Writer, lines ...string) {
+	t.Helper()
+
+	for _, line := range lines {
+		_, err := w.Write([]byte(line))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// writeRotated writes data to path compressed, like a rotated file.
+func writeRotated(t *testing.T, path string, data string) {
+	t.Helper()
+
+	tmp := path + ".tmp"
+
+	err := os.WriteFile(tmp, []byte(data), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = compressFile(tmp, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Remove(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readLog(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+func TestWriter_RotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+
+	w, err := Open(path, 20, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// 11 + 8 bytes fit, the third line does not
+	writeLines(t, w, "first line\n", "second\n")
+
+	if files := Files(path, 3); len(files) != 1 {
+		t.Fatalf("rotated too early: %v", files)
+	}
+
+	writeLines(t, w, "third\n")
+
+	files := Files(path, 3)
+	if len(files) != 2 || files[0] != rotatedPath(path, 1) {
+		t.Fatalf("got files %v, want one rotated file", files)
+	}
+
+	if got := readLog(t, files[0]); got != "first line\nsecond\n" {
+		t.Fatalf("rotated file: got %q", got)
+	}
+
+	if got := readLog(t, path); got != "third\n" {
+		t.Fatalf("current file: got %q", got)
+	}
+}
+
+func TestWriter_RotatedFilesAreGzipped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+
+	w, err := Open(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	writeLines(t, w, "0123456789\n", "next\n")
+
+	data, err := os.ReadFile(rotatedPath(path, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		t.Fatalf("rotated file is not gzipped: %q", data)
+	}
+
+	if got := readLog(t, rotatedPath(path, 1)); got != "0123456789\n" {
+		t.Fatalf("got %q after decompressing", got)
+	}
+}
+
+func TestWriter_PrunesOldFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+
+	w, err := Open(path, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// Every line after the first rotates the file
+	for i := 0; i < 5; i++ {
+		writeLines(t, w, "line "+strconv.Itoa(i)+"\n")
+	}
+
+	files := Files(path, 5)
+	if len(files) != 3 {
+		t.Fatalf("got files %v, want 2 rotated files and the current file", files)
+	}
+
+	if _, err := os.Stat(rotatedPath(path, 3)); !os.IsNotExist(err) {
+		t.Fatalf("oldest file was not removed - %v", err)
+	}
+
+	for i, want := range []string{"line 2\n", "line 3\n", "line 4\n"} {
+		if got := readLog(t, files[i]); got != want {
+			t.Fatalf("file %s: got %q, want %q", files[i], got, want)
+		}
+	}
+}
+
+func TestWriter_RecoversFromFailedRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+
+	// A directory where the rotated file should go makes
+	// compressing the current file fail
+	blocker := rotatedPath(path, 1)
+
+	err := os.MkdirAll(filepath.Join(blocker, "blocker"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := Open(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	writeLines(t, w, "0123456789\n")
+
+	_, err = w.Write([]byte("kept\n"))
+	if err == nil {
+		t.Fatal("expected the rotation to fail")
+	}
+
+	err = os.RemoveAll(blocker)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Logging carries on, and rotating works again
+	writeLines(t, w, "after\n")
+
+	if got := readLog(t, rotatedPath(path, 1)); got != "0123456789\nkept\n" {
+		t.Fatalf("rotated file: got %q", got)
+	}
+
+	if got := readLog(t, path); got != "after\n" {
+		t.Fatalf("current file: got %q", got)
+	}
+}
+
+func TestWriter_Rename(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.log")
+	newPath := filepath.Join(dir, "new.log")
+
+	w, err := Open(oldPath, 20, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// Write enough to rotate once
+	for _, line := range []string{"first line\n", "second line\n"} {
+		_, err = w.Write([]byte(line))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Log files left at the new path are kept as older files
+	err = os.WriteFile(newPath, []byte("existing\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeRotated(t, rotatedPath(newPath, 2), "older\n")
+
+	err = w.Rename(newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = w.Write([]byte("third\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if files := Files(oldPath, 3); len(files) != 0 {
+		t.Fatalf("old log files were left behind: %v", files)
+	}
+
+	want := []struct {
+		path string
+		data string
+	}{
+		{path: rotatedPath(newPath, 3), data: "older\n"},
+		{path: rotatedPath(newPath, 2), data: "existing\n"},
+		{path: rotatedPath(newPath, 1), data: "first line\n"},
+		{path: newPath, data: "second line\nthird\n"},
+	}
+
+	files := Files(newPath, 3)
+	if len(files) != len(want) {
+		t.Fatalf("got files %v, want %d files", files, len(want))
+	}
+
+	for i, file := range want {
+		if files[i] != file.path {
+			t.Fatalf("file %d: got %s, want %s", i, files[i], file.path)
+		}
+
+		if data := readLog(t, file.path); data != file.data {
+			t.Fatalf("%s: got %q, want %q", file.path, data, file.data)
+		}
+	}
+
+	assertNoAsideDirs(t, dir)
+}
+
+func TestWriter_RenamePrunesExistingFiles(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.log")
+	newPath := filepath.Join(dir, "new.log")
+
+	w, err := Open(oldPath, 20, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	writeLines(t, w, "first line\n", "second line\n")
+
+	for i := 1; i <= 2; i++ {
+		writeRotated(t, rotatedPath(newPath, i), "existing "+strconv.Itoa(i))
+	}
+
+	err = w.Rename(newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := Files(newPath, 10)
+	if len(files) != 3 {
+		t.Fatalf("got files %v, want 2 rotated files and the current file", files)
+	}
+
+	// Only the newest existing file fits
+	if data := readLog(t, rotatedPath(newPath, 2)); data != "existing 1" {
+		t.Fatalf("got %q, want the newest existing file", data)
+	}
+
+	assertNoAsideDirs(t, dir)
+}
+
+func assertNoAsideDirs(t *testing.T, dir string) {
+	t.Helper()
+
+	aside, err := filepath.Glob(filepath.Join(dir, "*.old-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(aside) != 0 {
+		t.Fatalf("temporary directories were left behind: %v", aside)
+	}
+}
@@ -0,0 +1,188 @@
+package wguctl
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+type LogLevel int
+
+const (
+	UnknownLogLevel LogLevel = iota
+	DebugLogLevel
+	InfoLogLevel
+	WarnLogLevel
+	ErrorLogLevel
+)
+
+func (o LogLevel) String() string {
+	switch o {
+	case DebugLogLevel:
+		return "debug"
+	case InfoLogLevel:
+		return "info"
+	case WarnLogLevel:
+		return "warn"
+	case ErrorLogLevel:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+type EventKind int
+
+const (
+	// LogEventKind is a line that has no more specific meaning.
+	LogEventKind EventKind = iota
+	HandshakeCompletedEventKind
+	HandshakeFailedEventKind
+	EndpointChangedEventKind
+	ErrorEventKind
+)
+
+func (o EventKind) String() string {
+	switch o {
+	case HandshakeCompletedEventKind:
+		return "handshake completed"
+	case HandshakeFailedEventKind:
+		return "handshake failed"
+	case EndpointChangedEventKind:
+		return "endpoint changed"
+	case ErrorEventKind:
+		return "error"
+	default:
+		return "log"
+	}
+}
+
+// Event is a wgu stderr line parsed into what it means.
+type Event struct {
+	Kind  EventKind
+	Level LogLevel
+
+	// Time is the timestamp wgu logged, or when the line was
+	// received if it did not have one.
+	Time time.Time
+
+	// Peer is the (possibly abbreviated) public key of the peer
+	// the line is about, if any.
+	Peer string
+
+	// Endpoint is the new endpoint for EndpointChangedEventKind.
+	Endpoint string
+
+	// Message is the line without its level and timestamp prefixes.
+	Message string
+
+	Line LogLine
+}
+
+var (
+	levelPrefixRe  = regexp.MustCompile(`(?i)^(?:\[(debug|info|warn|warning|error|fatal)\]|(debug|info|warn|warning|error|fatal):|level=(debug|info|warn|warning|error|fatal))\s*`)
+	devicePrefixRe = regexp.MustCompile(`^\([^)]*\)\s*`)
+	goLogTimeRe    = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)?)\s*`)
+	rfc3339TimeRe  = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:\d{2}))\s*`)
+	peerRe         = regexp.MustCompile(`peer\(([^)]+)\)`)
+	endpointRe     = regexp.MustCompile(`(\[[0-9a-fA-F:.]+\]:\d+|\d{1,3}(?:\.\d{1,3}){3}:\d+)`)
+)
+
+// ParseLogLine parses a wgu stderr line. It understands the
+// wireguard-go device logger format, e.g.:
+//
+//	DEBUG: (wg0) 2024/01/02 15:04:05 peer(AbCd…WxYz) - Received handshake response
+//
+// as well as plain Go log lines. Lines it does not recognize are
+// returned as LogEventKind events with the full text as the message.
+func ParseLogLine(line LogLine) Event {
+	event := Event{
+		Kind: LogEventKind,
+		Time: line.Time,
+		Line: line,
+	}
+
+	rest := strings.TrimSpace(line.Text)
+
+	rest = parseLevelPrefix(rest, &event)
+	rest = devicePrefixRe.ReplaceAllString(rest, "")
+
+	if m := goLogTimeRe.FindStringSubmatch(rest); m != nil {
+		t, err := time.ParseInLocation("2006/01/02 15:04:05", m[1], time.Local)
+		if err == nil {
+			event.Time = t
+		}
+
+		rest = rest[len(m[0]):]
+	} else if m := rfc3339TimeRe.FindStringSubmatch(rest); m != nil {
+		t, err := time.Parse(time.RFC3339Nano, m[1])
+		if err == nil {
+			event.Time = t
+		}
+
+		rest = rest[len(m[0]):]
+	}
+
+	// Some loggers put the level after the timestamp
+	if event.Level == UnknownLogLevel {
+		rest = parseLevelPrefix(rest, &event)
+	}
+
+	event.Message = rest
+
+	if m := peerRe.FindStringSubmatch(rest); m != nil {
+		event.Peer = m[1]
+	}
+
+	lower := strings.ToLower(rest)
+
+	switch {
+	case strings.Contains(lower, "received handshake response"),
+		strings.Contains(lower, "handshake completed"),
+		strings.Contains(lower, "handshake complete "):
+		event.Kind = HandshakeCompletedEventKind
+	case strings.Contains(lower, "handshake did not complete"),
+		strings.Contains(lower, "handshake failed"):
+		event.Kind = HandshakeFailedEventKind
+	case strings.Contains(lower, "endpoint") &&
+		(strings.Contains(lower, "changed") || strings.Contains(lower, "updated") || strings.Contains(lower, "roam")):
+		event.Kind = EndpointChangedEventKind
+		if m := endpointRe.FindStringSubmatch(rest); m != nil {
+			event.Endpoint = m[1]
+		}
+	case event.Level == ErrorLogLevel,
+		strings.HasPrefix(lower, "error"),
+		strings.HasPrefix(lower, "failed"):
+		event.Kind = ErrorEventKind
+	}
+
+	if event.Level == UnknownLogLevel && event.Kind == ErrorEventKind {
+		event.Level = ErrorLogLevel
+	}
+
+	return event
+}
+
+// parseLevelPrefix sets the event's level from a leading level
+// prefix and returns the rest of the line.
+func parseLevelPrefix(str string, event *Event) string {
+	m := levelPrefixRe.FindStringSubmatch(str)
+	if m == nil {
+		return str
+	}
+
+	name := m[1] + m[2] + m[3]
+
+	switch strings.ToLower(name) {
+	case "debug":
+		event.Level = DebugLogLevel
+	case "info":
+		event.Level = InfoLogLevel
+	case "warn", "warning":
+		event.Level = WarnLogLevel
+	case "error", "fatal":
+		event.Level = ErrorLogLevel
+	}
+
+	return str[len(m[0]):]
+}
@@ -0,0 +1,216 @@
+package wguctl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLogLine(t *testing.T) {
+	received := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	goLogTime := time.Date(2024, 1, 2, 15, 4, 5, 0, time.Local)
+
+	tests := []struct {
+		name string
+		text string
+		want Event
+	}{
+		{
+			name: "wireguard-go handshake response",
+			text: "DEBUG: (wg0) 2024/01/02 15:04:05 peer(AbCd…WxYz) - Received handshake response",
+			want: Event{
+				Kind:    HandshakeCompletedEventKind,
+				Level:   DebugLogLevel,
+				Time:    goLogTime,
+				Peer:    "AbCd…WxYz",
+				Message: "peer(AbCd…WxYz) - Received handshake response",
+			},
+		},
+		{
+			name: "wireguard-go handshake retry",
+			text: "DEBUG: (wg0) 2024/01/02 15:04:05 peer(AbCd…WxYz) - Handshake did not complete after 5 seconds, retrying (try 2)",
+			want: Event{
+				Kind:    HandshakeFailedEventKind,
+				Level:   DebugLogLevel,
+				Time:    goLogTime,
+				Peer:    "AbCd…WxYz",
+				Message: "peer(AbCd…WxYz) - Handshake did not complete after 5 seconds, retrying (try 2)",
+			},
+		},
+		{
+			name: "wireguard-go keepalive",
+			text: "DEBUG: (wg0) 2024/01/02 15:04:05 peer(AbCd…WxYz) - Sending keepalive packet",
+			want: Event{
+				Kind:    LogEventKind,
+				Level:   DebugLogLevel,
+				Time:    goLogTime,
+				Peer:    "AbCd…WxYz",
+				Message: "peer(AbCd…WxYz) - Sending keepalive packet",
+			},
+		},
+		{
+			name: "endpoint changed to IPv4",
+			text: "INFO: (wg0) 2024/01/02 15:04:05 peer(AbCd…WxYz) - Endpoint changed to 203.0.113.5:51820",
+			want: Event{
+				Kind:     EndpointChangedEventKind,
+				Level:    InfoLogLevel,
+				Time:     goLogTime,
+				Peer:     "AbCd…WxYz",
+				Endpoint: "203.0.113.5:51820",
+				Message:  "peer(AbCd…WxYz) - Endpoint changed to 203.0.113.5:51820",
+			},
+		},
+		{
+			name: "endpoint roamed to IPv6",
+			text: "DEBUG: (wg0) 2024/01/02 15:04:05 peer(AbCd…WxYz) - Endpoint roamed to [2001:db8::1]:51820",
+			want: Event{
+				Kind:     EndpointChangedEventKind,
+				Level:    DebugLogLevel,
+				Time:     goLogTime,
+				Peer:     "AbCd…WxYz",
+				Endpoint: "[2001:db8::1]:51820",
+				Message:  "peer(AbCd…WxYz) - Endpoint roamed to [2001:db8::1]:51820",
+			},
+		},
+		{
+			name: "wireguard-go error",
+			text: "ERROR: (wg0) 2024/01/02 15:04:05 Failed to send handshake initiation: no known endpoint for peer",
+			want: Event{
+				Kind:    ErrorEventKind,
+				Level:   ErrorLogLevel,
+				Time:    goLogTime,
+				Message: "Failed to send handshake initiation: no known endpoint for peer",
+			},
+		},
+		{
+			name: "go log line",
+			text: "2024/01/02 15:04:05 wgu: tunnel is up",
+			want: Event{
+				Kind:    LogEventKind,
+				Time:    goLogTime,
+				Message: "wgu: tunnel is up",
+			},
+		},
+		{
+			name: "go log line with microseconds and a level after the time",
+			text: "2024/01/02 15:04:05.250000 [warn] peer(AbCd…WxYz) has no allowed IPs",
+			want: Event{
+				Kind:    LogEventKind,
+				Level:   WarnLogLevel,
+				Time:    goLogTime.Add(250 * time.Millisecond),
+				Peer:    "AbCd…WxYz",
+				Message: "peer(AbCd…WxYz) has no allowed IPs",
+			},
+		},
+		{
+			name: "go log line without a level that is an error",
+			text: "2024/01/02 15:04:05 error: failed to configure device",
+			want: Event{
+				Kind:    ErrorEventKind,
+				Level:   ErrorLogLevel,
+				Time:    goLogTime,
+				Message: "failed to configure device",
+			},
+		},
+		{
+			name: "RFC 3339 line",
+			text: "2024-01-02T15:04:05.5+02:00 level=info handshake completed with peer(AbCd…WxYz)",
+			want: Event{
+				Kind:    HandshakeCompletedEventKind,
+				Level:   InfoLogLevel,
+				Time:    time.Date(2024, 1, 2, 15, 4, 5, 5e8, time.FixedZone("", 2*60*60)),
+				Peer:    "AbCd…WxYz",
+				Message: "handshake completed with peer(AbCd…WxYz)",
+			},
+		},
+		{
+			name: "RFC 3339 line in UTC",
+			text: "2024-01-02T15:04:05Z info: listening on 0.0.0.0:51820",
+			want: Event{
+				Kind:    LogEventKind,
+				Level:   InfoLogLevel,
+				Time:    time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC),
+				Message: "listening on 0.0.0.0:51820",
+			},
+		},
+		{
+			name: "endpoint mentioned without a change",
+			text: "peer endpoint is 203.0.113.5:51820",
+			want: Event{
+				Kind:    LogEventKind,
+				Time:    received,
+				Message: "peer endpoint is 203.0.113.5:51820",
+			},
+		},
+		{
+			name: "error in the middle of a line",
+			text: "the error count is zero",
+			want: Event{
+				Kind:    LogEventKind,
+				Time:    received,
+				Message: "the error count is zero",
+			},
+		},
+		{
+			name: "malformed timestamp",
+			text: "2024/13/45 99:99:99 not really a time",
+			want: Event{
+				Kind:    LogEventKind,
+				Time:    received,
+				Message: "not really a time",
+			},
+		},
+		{
+			name: "garbage",
+			text: "\x00\x01{\"not\": \"a log line\"}",
+			want: Event{
+				Kind:    LogEventKind,
+				Time:    received,
+				Message: "\x00\x01{\"not\": \"a log line\"}",
+			},
+		},
+		{
+			name: "empty",
+			text: "   ",
+			want: Event{
+				Kind: LogEventKind,
+				Time: received,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			line := LogLine{Seq: 1, Time: received, Text: test.text}
+
+			got := ParseLogLine(line)
+
+			if got.Line != line {
+				t.Errorf("line: got %+v, want %+v", got.Line, line)
+			}
+
+			if got.Kind != test.want.Kind {
+				t.Errorf("kind: got %s, want %s", got.Kind, test.want.Kind)
+			}
+
+			if got.Level != test.want.Level {
+				t.Errorf("level: got %s, want %s", got.Level, test.want.Level)
+			}
+
+			if !got.Time.Equal(test.want.Time) {
+				t.Errorf("time: got %s, want %s", got.Time, test.want.Time)
+			}
+
+			if got.Peer != test.want.Peer {
+				t.Errorf("peer: got %q, want %q", got.Peer, test.want.Peer)
+			}
+
+			if got.Endpoint != test.want.Endpoint {
+				t.Errorf("endpoint: got %q, want %q", got.Endpoint, test.want.Endpoint)
+			}
+
+			if got.Message != test.want.Message {
+				t.Errorf("message: got %q, want %q", got.Message, test.want.Message)
+			}
+		})
+	}
+}
@@ -2,9 +2,19 @@ package wguctl
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// tunnelEventsBufferSize is how many unread events the Fsm's
+// events channel holds before dropping new ones.
+const tunnelEventsBufferSize = 100
+
 type FsmState int
 
 const (
@@ -14,22 +24,133 @@ const (
 	ErrorFsmState
 	DisconnectingFsmState
 	ConnectingFsmState
+	ReconnectingFsmState
 )
 
+func (o FsmState) String() string {
+	switch o {
+	case DisconnectedFsmState:
+		return "disconnected"
+	case ConnectedFsmState:
+		return "connected"
+	case ErrorFsmState:
+		return "error"
+	case DisconnectingFsmState:
+		return "disconnecting"
+	case ConnectingFsmState:
+		return "connecting"
+	case ReconnectingFsmState:
+		return "reconnecting"
+	default:
+		return "unknown"
+	}
+}
+
+// maxHistoryLen is the number of state transitions an Fsm remembers.
+const maxHistoryLen = 100
+
+// subscriberBufferSize is how many unread state changes a
+// subscriber's channel holds before the oldest ones are dropped.
+const subscriberBufferSize = 16
+
+// StateChange describes a transition from one state to another.
+type StateChange struct {
+	From FsmState
+	To   FsmState
+	Err  error
+
+	// Cause is a short description of what triggered the transition.
+	Cause string
+
+	At time.Time
+}
+
 type FsmConfig struct {
 	OnNewStderr func(ctx context.Context)
+	Reconnect   ReconnectPolicy
+
+	// LogCapacity is the number of wgu stderr lines kept in memory.
+	// The oldest lines are dropped once it is reached.
+	LogCapacity int
+
+	// OnStatus is called when wgu reports a new status.
+	OnStatus func(ctx context.Context)
+
+	// OptLogWriter, if set, receives every wgu stderr line prefixed
+	// with LogFileTimeFormat, and LogFileSessionMarker at the start
+	// of every connection attempt.
+	OptLogWriter io.Writer
+}
+
+const (
+	LogFileTimeFormat    = "2006/01/02 15:04:05.000"
+	LogFileSessionMarker = "--- wgu session started ---"
+)
+
+// ReconnectPolicy controls how the Fsm retries after the tunnel drops
+// or fails to come up. The zero value disables reconnecting.
+type ReconnectPolicy struct {
+	// MaxAttempts is the number of reconnect attempts made before
+	// giving up and entering ErrorFsmState.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first attempt. Each
+	// following attempt doubles the delay up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// Jitter randomizes each delay by up to this fraction of itself,
+	// e.g. 0.2 means +/- 20%.
+	Jitter float64
+}
+
+func (o ReconnectPolicy) enabled() bool {
+	return o.MaxAttempts > 0
+}
+
+// backoff returns the delay before the given (1-based) attempt.
+func (o ReconnectPolicy) backoff(attempt int) time.Duration {
+	delay := o.InitialBackoff
+	for i := 1; i < attempt && (o.MaxBackoff <= 0 || delay < o.MaxBackoff); i++ {
+		delay *= 2
+	}
+
+	if o.MaxBackoff > 0 && delay > o.MaxBackoff {
+		delay = o.MaxBackoff
+	}
+
+	if o.Jitter > 0 {
+		delay += time.Duration(float64(delay) * o.Jitter * (2*rand.Float64() - 1))
+	}
+
+	if delay < 0 {
+		delay = 0
+	}
+
+	return delay
+}
+
+// ReconnectStatus describes a pending reconnect attempt.
+type ReconnectStatus struct {
+	Attempt     int
+	MaxAttempts int
+	Next        time.Time
 }
 
 func NewFsm(ctx context.Context, config FsmConfig) *Fsm {
 	wctx, cancelFn := context.WithCancel(ctx)
 
 	fsm := &Fsm{
-		config:   config,
-		events:   make(chan interface{}, 10),
-		state:    DisconnectedFsmState,
-		stderrCh: make(chan string),
-		done:     make(chan struct{}),
-		cancelFn: cancelFn,
+		config:       config,
+		events:       make(chan fsmEvent, 10),
+		connResults:  make(chan connectResultFsmEvent),
+		state:        DisconnectedFsmState,
+		logs:         newLogBuffer(config.LogCapacity),
+		tunnelEvents: make(chan Event, tunnelEventsBufferSize),
+		stderrCh:     make(chan string),
+		statusCh:     make(chan Status),
+		done:         make(chan struct{}),
+		cancelFn:     cancelFn,
 	}
 
 	go fsm.loop(wctx)
@@ -40,17 +161,32 @@ func NewFsm(ctx context.Context, config FsmConfig) *Fsm {
 }
 
 type Fsm struct {
-	config     FsmConfig
-	wgu        *Wgu
-	events     chan interface{}
-	rwMutex    sync.RWMutex
-	state      FsmState
-	lastError  error
-	stderrRWMu sync.RWMutex
-	stderr     string
-	stderrCh   chan string
-	done       chan struct{}
-	cancelFn   func()
+	config        FsmConfig
+	wgu           *Wgu
+	wguConfig     Config
+	connCancelFn  func()
+	connAttempt   int
+	connResults   chan connectResultFsmEvent
+	retryTimer    *time.Timer
+	events        chan fsmEvent
+	rwMutex       sync.RWMutex
+	state         FsmState
+	lastError     error
+	reconnect     ReconnectStatus
+	history       []StateChange
+	subsMu        sync.Mutex
+	subs          map[int]chan StateChange
+	nextSubId     int
+	logs          *logBuffer
+	session       atomic.Uint64
+	stderrCh      chan string
+	statusCh      chan Status
+	statusRWMu    sync.RWMutex
+	status        Status
+	runningConfig []byte
+	tunnelEvents  chan Event
+	done          chan struct{}
+	cancelFn      func()
 }
 
 func (o *Fsm) Connect(ctx context.Context, config Config) error {
@@ -71,6 +207,18 @@ func (o *Fsm) Disconnect(ctx context.Context) error {
 	}
 }
 
+// MoveConfig tells the Fsm that its config file was moved to
+// configPath. A running wgu process is left alone, but reconnect
+// attempts will use the new path.
+func (o *Fsm) MoveConfig(ctx context.Context, configPath string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case o.events <- moveConfigFsmEvent{configPath: configPath}:
+		return nil
+	}
+}
+
 func (o *Fsm) Destroy(ctx context.Context) {
 	o.cancelFn()
 
@@ -80,13 +228,6 @@ func (o *Fsm) Destroy(ctx context.Context) {
 	}
 }
 
-type connectFsmEvent struct {
-	config Config
-}
-
-type disconnectFsmEvent struct {
-}
-
 func (o *Fsm) loop(ctx context.Context) {
 	defer close(o.done)
 
@@ -98,74 +239,259 @@ func (o *Fsm) loop(ctx context.Context) {
 			}
 			return
 		case e := <-o.events:
-			o.processEvent(ctx, e)
+			o.dispatch(ctx, e)
+		case <-o.wguExited():
+			o.dispatch(ctx, wguExitedFsmEvent{})
+		case <-o.retryTimerC():
+			o.dispatch(ctx, retryFsmEvent{})
+		case result := <-o.connResults:
+			o.dispatch(ctx, result)
 		}
 	}
 }
 
-func (o *Fsm) Done() <-chan struct{} {
-	return o.done
+// wguExited returns the exit channel of the running wgu process,
+// or nil if there is none so that it blocks forever in a select.
+func (o *Fsm) wguExited() <-chan struct{} {
+	if o.wgu == nil {
+		return nil
+	}
+
+	return o.wgu.Exited()
+}
+
+// retryTimerC returns the channel of the pending reconnect timer,
+// or nil if no reconnect is scheduled.
+func (o *Fsm) retryTimerC() <-chan time.Time {
+	if o.retryTimer == nil {
+		return nil
+	}
+
+	return o.retryTimer.C
+}
+
+// failed schedules a reconnect attempt if the reconnect policy
+// allows another one, otherwise it enters ErrorFsmState.
+func (o *Fsm) failed(err error, cause string) {
+	o.rwMutex.Lock()
+	defer o.rwMutex.Unlock()
+
+	policy := o.config.Reconnect
+	if !policy.enabled() {
+		o.setStateLocked(ErrorFsmState, err, cause)
+		return
+	}
+
+	attempt := o.reconnect.Attempt + 1
+	if attempt > policy.MaxAttempts {
+		o.setStateLocked(ErrorFsmState, fmt.Errorf("gave up after %d reconnect attempts - %w",
+			policy.MaxAttempts, err), cause)
+		o.reconnect = ReconnectStatus{}
+		return
+	}
+
+	delay := policy.backoff(attempt)
+
+	o.setStateLocked(ReconnectingFsmState, err, cause)
+	o.reconnect = ReconnectStatus{
+		Attempt:     attempt,
+		MaxAttempts: policy.MaxAttempts,
+		Next:        time.Now().Add(delay),
+	}
+	o.retryTimer = time.NewTimer(delay)
 }
 
-func (o *Fsm) processEvent(ctx context.Context, event interface{}) {
-	switch e := event.(type) {
-	case connectFsmEvent:
-		o.rwMutex.Lock()
-		o.state = ConnectingFsmState
-		o.rwMutex.Unlock()
+// connected records a successful connection and resets the
+// reconnect attempt counter.
+func (o *Fsm) connected(cause string) {
+	o.rwMutex.Lock()
+	defer o.rwMutex.Unlock()
+
+	o.setStateLocked(ConnectedFsmState, nil, cause)
+	o.reconnect = ReconnectStatus{}
+}
+
+// cancelRetry stops any pending reconnect attempt.
+func (o *Fsm) cancelRetry() {
+	if o.retryTimer != nil {
+		o.retryTimer.Stop()
+		o.retryTimer = nil
+	}
+
+	o.rwMutex.Lock()
+	o.reconnect = ReconnectStatus{}
+	o.rwMutex.Unlock()
+}
+
+// setState changes the state and records the transition, and what
+// caused it, in the history.
+func (o *Fsm) setState(state FsmState, err error, cause string) {
+	o.rwMutex.Lock()
+	defer o.rwMutex.Unlock()
+
+	o.setStateLocked(state, err, cause)
+}
+
+// setStateLocked is setState for callers that hold rwMutex.
+func (o *Fsm) setStateLocked(state FsmState, err error, cause string) {
+	o.history = append(o.history, StateChange{
+		From:  o.state,
+		To:    state,
+		Err:   err,
+		Cause: cause,
+		At:    time.Now(),
+	})
+
+	if len(o.history) > maxHistoryLen {
+		o.history = o.history[len(o.history)-maxHistoryLen:]
+	}
+
+	o.state = state
+	o.lastError = err
+
+	o.publishStateChange(o.history[len(o.history)-1])
+}
+
+// Subscribe returns a channel that receives every state change and a
+// function that ends the subscription. The first value received
+// describes the current state. Subscribers never block the Fsm: if a
+// subscriber falls behind, its oldest unread changes are dropped.
+func (o *Fsm) Subscribe() (<-chan StateChange, func()) {
+	ch := make(chan StateChange, subscriberBufferSize)
+
+	o.rwMutex.RLock()
+	ch <- StateChange{
+		From: o.state,
+		To:   o.state,
+		Err:  o.lastError,
+		At:   time.Now(),
+	}
+
+	o.subsMu.Lock()
+	id := o.nextSubId
+	o.nextSubId++
+	if o.subs == nil {
+		o.subs = make(map[int]chan StateChange)
+	}
+	o.subs[id] = ch
+	o.subsMu.Unlock()
+	o.rwMutex.RUnlock()
+
+	unsubscribe := func() {
+		o.subsMu.Lock()
+		defer o.subsMu.Unlock()
+
+		if _, ok := o.subs[id]; ok {
+			delete(o.subs, id)
+			close(ch)
+		}
+	}
 
-		err := o.connect(ctx, e.config)
+	return ch, unsubscribe
+}
 
-		o.rwMutex.Lock()
-		defer o.rwMutex.Unlock()
+func (o *Fsm) publishStateChange(change StateChange) {
+	o.subsMu.Lock()
+	defer o.subsMu.Unlock()
 
-		if err != nil {
-			o.state = ErrorFsmState
-			o.lastError = err
-		} else {
-			o.state = ConnectedFsmState
-			o.lastError = nil
+	for _, ch := range o.subs {
+		select {
+		case ch <- change:
+			continue
+		default:
 		}
-	case disconnectFsmEvent:
-		o.rwMutex.Lock()
-		o.state = DisconnectingFsmState
-		o.rwMutex.Unlock()
-
-		err := o.disconnect(ctx)
-
-		o.rwMutex.Lock()
-		defer o.rwMutex.Unlock()
-
-		if err != nil {
-			o.state = ErrorFsmState
-			o.lastError = err
-		} else {
-			o.state = DisconnectedFsmState
-			o.lastError = nil
+
+		// Make room by dropping the oldest unread change
+		select {
+		case <-ch:
+		default:
+		}
+
+		select {
+		case ch <- change:
+		default:
 		}
 	}
 }
 
-func (o *Fsm) connect(ctx context.Context, config Config) error {
+func (o *Fsm) Done() <-chan struct{} {
+	return o.done
+}
+
+// startConnect starts wgu in the background under its own context,
+// so that the attempt can be cancelled while it is in progress. The
+// result is delivered to the loop as a connectResultFsmEvent.
+func (o *Fsm) startConnect(ctx context.Context, config Config) {
 	if o.wgu != nil {
 		_ = o.wgu.Stop()
 		o.wgu = nil
 	}
 
+	o.cancelConnect()
+
 	config.OptStderr = o.stderrCh
+	config.OptStatus = o.statusCh
+
+	o.setStatus(Status{})
+
+	o.session.Add(1)
+	o.writeLogFile(time.Now(), LogFileSessionMarker)
+
+	// The process is killed when connCtx is cancelled,
+	// so it must live for as long as wgu runs.
+	connCtx, cancelFn := context.WithCancel(ctx)
+	o.connCancelFn = cancelFn
+	o.connAttempt++
+	attempt := o.connAttempt
+
+	go func() {
+		// Remember what the config looked like when wgu read it,
+		// so that later changes to it can be detected
+		configData, readErr := os.ReadFile(config.ConfigPath)
+		if readErr != nil {
+			configData = nil
+		}
 
-	wgu, err := StartWgu(ctx, config)
-	if err != nil {
-		return err
-	}
+		wgu, err := StartWgu(connCtx, config)
 
-	o.wgu = wgu
-	return nil
+		result := connectResultFsmEvent{
+			attempt: attempt,
+			wgu:     wgu,
+			err:     err,
+			config:  configData,
+		}
+
+		select {
+		case <-ctx.Done():
+			if wgu != nil {
+				_ = wgu.Stop()
+			}
+		case o.connResults <- result:
+		}
+	}()
+}
+
+// cancelConnect cancels the context of the current connect attempt,
+// which aborts it if it is in progress and kills its wgu process.
+func (o *Fsm) cancelConnect() {
+	if o.connCancelFn != nil {
+		o.connCancelFn()
+		o.connCancelFn = nil
+	}
 }
 
 func (o *Fsm) disconnect(ctx context.Context) error {
-	_ = o.wgu.Stop()
+	if o.wgu == nil {
+		return nil
+	}
+
+	err := o.wgu.Stop()
 	o.wgu = nil
+	o.cancelConnect()
+	if err != nil {
+		return fmt.Errorf("failed to stop wgu - %w", err)
+	}
+
 	return nil
 }
 
@@ -176,11 +502,108 @@ func (o *Fsm) State() (FsmState, error) {
 	return o.state, o.lastError
 }
 
+// History returns the most recent state transitions, oldest first.
+func (o *Fsm) History() []StateChange {
+	o.rwMutex.RLock()
+	defer o.rwMutex.RUnlock()
+
+	history := make([]StateChange, len(o.history))
+	copy(history, o.history)
+
+	return history
+}
+
+// Reconnect returns the status of the pending reconnect attempt.
+// It is only meaningful in ReconnectingFsmState.
+func (o *Fsm) Reconnect() ReconnectStatus {
+	o.rwMutex.RLock()
+	defer o.rwMutex.RUnlock()
+
+	return o.reconnect
+}
+
+// Stderr returns the buffered wgu stderr lines as a single string.
 func (o *Fsm) Stderr() string {
-	o.stderrRWMu.RLock()
-	defer o.stderrRWMu.RUnlock()
+	return o.logs.String()
+}
 
-	return o.stderr
+// LogBounds returns the sequence numbers of the oldest and newest
+// buffered wgu stderr lines. first is greater than last if there
+// are none.
+func (o *Fsm) LogBounds() (first uint64, last uint64) {
+	return o.logs.bounds()
+}
+
+// LogLine returns the buffered wgu stderr line with the given
+// sequence number, or false if it is no longer buffered.
+func (o *Fsm) LogLine(seq uint64) (LogLine, bool) {
+	return o.logs.get(seq)
+}
+
+// LogLines returns the buffered wgu stderr lines with a sequence
+// number greater than since, oldest first. Pass 0 to get all of them.
+func (o *Fsm) LogLines(since uint64) []LogLine {
+	return o.logs.since(since)
+}
+
+// Events returns a channel that receives the wgu stderr lines that
+// were recognized as tunnel events, such as completed handshakes.
+// Plain log lines are only available through LogLines. Events are
+// dropped if the channel is not drained quickly enough, so that
+// wgu's stderr never backs up.
+func (o *Fsm) Events() <-chan Event {
+	return o.tunnelEvents
+}
+
+func (o *Fsm) publishEvent(event Event) {
+	select {
+	case o.tunnelEvents <- event:
+	default:
+	}
+}
+
+// RunningConfig returns the contents of the config file when the
+// connected wgu was started. It returns false if the Fsm is not
+// connected or the config could not be read.
+func (o *Fsm) RunningConfig() (string, bool) {
+	o.rwMutex.RLock()
+	defer o.rwMutex.RUnlock()
+
+	if o.state != ConnectedFsmState || o.runningConfig == nil {
+		return "", false
+	}
+
+	return string(o.runningConfig), true
+}
+
+// Status returns the latest status wgu reported for the current
+// connection, and false if it has not reported any.
+func (o *Fsm) Status() (Status, bool) {
+	o.statusRWMu.RLock()
+	defer o.statusRWMu.RUnlock()
+
+	return o.status, !o.status.UpdatedAt.IsZero()
+}
+
+func (o *Fsm) setStatus(status Status) {
+	o.statusRWMu.Lock()
+	defer o.statusRWMu.Unlock()
+
+	o.status = status
+}
+
+// Session returns the id of the most recent connection attempt.
+// Log lines are tagged with the session they were received in.
+func (o *Fsm) Session() uint64 {
+	return o.session.Load()
+}
+
+func (o *Fsm) writeLogFile(at time.Time, text string) {
+	if o.config.OptLogWriter == nil {
+		return
+	}
+
+	_, _ = fmt.Fprintf(o.config.OptLogWriter, "%s %s\n", at.Format(LogFileTimeFormat), text)
 }
 
 func (o *Fsm) handleStderr(ctx context.Context) {
@@ -188,12 +611,29 @@ func (o *Fsm) handleStderr(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			return
-		case line := <-o.stderrCh:
-			o.stderrRWMu.Lock()
+		case status := <-o.statusCh:
+			o.setStatus(status)
+
+			if o.config.OnStatus != nil {
+				o.config.OnStatus(ctx)
+			}
+		case text := <-o.stderrCh:
+			line := LogLine{
+				Time:    time.Now(),
+				Session: o.session.Load(),
+				Text:    text,
+			}
+
+			event := ParseLogLine(line)
+			line.Level = event.Level
 
-			o.stderr += line + "\n"
+			event.Line = o.logs.add(line)
 
-			o.stderrRWMu.Unlock()
+			o.writeLogFile(line.Time, text)
+
+			if event.Kind != LogEventKind {
+				o.publishEvent(event)
+			}
 
 			if o.config.OnNewStderr != nil {
 				o.config.OnNewStderr(ctx)
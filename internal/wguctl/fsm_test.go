@@ -0,0 +1,458 @@
+package wguctl
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fsmTestTimeout bounds how long a test waits for the Fsm.
+const fsmTestTimeout = 10 * time.Second
+
+func newTestFsm(t *testing.T, config FsmConfig) *Fsm {
+	t.Helper()
+
+	fsm := NewFsm(context.Background(), config)
+
+	t.Cleanup(func() {
+		ctx, cancelFn := context.WithTimeout(context.Background(), fsmTestTimeout)
+		defer cancelFn()
+
+		fsm.Destroy(ctx)
+	})
+
+	return fsm
+}
+
+func subscribe(t *testing.T, fsm *Fsm) <-chan StateChange {
+	t.Helper()
+
+	changes, unsubscribe := fsm.Subscribe()
+	t.Cleanup(unsubscribe)
+
+	return changes
+}
+
+// waitForState reads state changes until the Fsm enters want,
+// failing the test if it does not happen in time.
+func waitForState(t *testing.T, changes <-chan StateChange, want FsmState) StateChange {
+	t.Helper()
+
+	timeout := time.After(fsmTestTimeout)
+
+	var last StateChange
+
+	for {
+		select {
+		case last = <-changes:
+			if last.To == want {
+				return last
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for state %s - last change: %+v", want, last)
+		}
+	}
+}
+
+// waitFor polls fn until it returns true, failing the test if it
+// does not happen in time.
+func waitFor(t *testing.T, what string, fn func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(fsmTestTimeout)
+
+	for !fn() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func connect(t *testing.T, fsm *Fsm, config Config) {
+	t.Helper()
+
+	err := fsm.Connect(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func disconnect(t *testing.T, fsm *Fsm) {
+	t.Helper()
+
+	err := fsm.Disconnect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFsm_ConnectDisconnect(t *testing.T) {
+	fsm := newTestFsm(t, FsmConfig{})
+	changes := subscribe(t, fsm)
+
+	waitForState(t, changes, DisconnectedFsmState)
+
+	connect(t, fsm, fakeConfig(t, fakeRunner{mode: "ready"}))
+
+	waitForState(t, changes, ConnectingFsmState)
+	waitForState(t, changes, ConnectedFsmState)
+
+	disconnect(t, fsm)
+
+	waitForState(t, changes, DisconnectingFsmState)
+	change := waitForState(t, changes, DisconnectedFsmState)
+	if change.Err != nil {
+		t.Fatalf("unexpected error: %v", change.Err)
+	}
+
+	// Each transition is delivered exactly once
+	select {
+	case change := <-changes:
+		t.Fatalf("unexpected state change after disconnecting: %+v", change)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	var got []FsmState
+	for _, change := range fsm.History() {
+		got = append(got, change.To)
+	}
+
+	want := []FsmState{
+		ConnectingFsmState,
+		ConnectedFsmState,
+		DisconnectingFsmState,
+		DisconnectedFsmState,
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("history: got %v, want %v", got, want)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("history: got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestFsm_DisconnectWguKilledByInterrupt(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupts are not supported on windows")
+	}
+
+	fsm := newTestFsm(t, FsmConfig{})
+	changes := subscribe(t, fsm)
+
+	connect(t, fsm, fakeConfig(t, fakeRunner{mode: "die-on-interrupt"}))
+	waitForState(t, changes, ConnectedFsmState)
+
+	disconnect(t, fsm)
+
+	change := waitForState(t, changes, DisconnectedFsmState)
+	if change.Err != nil {
+		t.Fatalf("unexpected error: %v", change.Err)
+	}
+
+	if state, err := fsm.State(); state != DisconnectedFsmState || err != nil {
+		t.Fatalf("got state %s with error %v, want %s", state, err, DisconnectedFsmState)
+	}
+}
+
+func TestFsm_DisconnectWhileDisconnected(t *testing.T) {
+	fsm := newTestFsm(t, FsmConfig{})
+
+	disconnect(t, fsm)
+
+	// Wait for the loop to handle the event
+	connect(t, fsm, fakeConfig(t, fakeRunner{mode: "ready"}))
+	waitFor(t, "connect", func() bool {
+		state, _ := fsm.State()
+		return state == ConnectedFsmState
+	})
+
+	if history := fsm.History(); history[0].To != ConnectingFsmState {
+		t.Fatalf("disconnect was not ignored - history: %v", history)
+	}
+}
+
+func TestFsm_ExitBeforeReady(t *testing.T) {
+	fsm := newTestFsm(t, FsmConfig{})
+	changes := subscribe(t, fsm)
+
+	connect(t, fsm, fakeConfig(t, fakeRunner{mode: "exit-before-ready"}))
+
+	change := waitForState(t, changes, ErrorFsmState)
+	if change.Err == nil {
+		t.Fatal("expected an error")
+	}
+
+	// Disconnecting clears the error
+	disconnect(t, fsm)
+
+	change = waitForState(t, changes, DisconnectedFsmState)
+	if change.Err != nil {
+		t.Fatalf("error was not cleared: %v", change.Err)
+	}
+}
+
+func TestFsm_CrashAfterReady(t *testing.T) {
+	fsm := newTestFsm(t, FsmConfig{})
+	changes := subscribe(t, fsm)
+
+	connect(t, fsm, fakeConfig(t, fakeRunner{mode: "crash-after-ready"}))
+
+	waitForState(t, changes, ConnectedFsmState)
+
+	change := waitForState(t, changes, ErrorFsmState)
+	if change.Err == nil || !strings.Contains(change.Err.Error(), "code 1") {
+		t.Fatalf("unexpected error: %v", change.Err)
+	}
+
+	if change.Cause != "wgu exited" {
+		t.Fatalf("unexpected cause: %q", change.Cause)
+	}
+}
+
+func TestFsm_ReconnectGivesUp(t *testing.T) {
+	fsm := newTestFsm(t, FsmConfig{
+		Reconnect: ReconnectPolicy{
+			MaxAttempts:    2,
+			InitialBackoff: 10 * time.Millisecond,
+		},
+	})
+	changes := subscribe(t, fsm)
+
+	connect(t, fsm, fakeConfig(t, fakeRunner{mode: "exit-before-ready"}))
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		waitForState(t, changes, ReconnectingFsmState)
+		waitForState(t, changes, ConnectingFsmState)
+	}
+
+	change := waitForState(t, changes, ErrorFsmState)
+	if !strings.Contains(change.Err.Error(), "gave up after 2 reconnect attempts") {
+		t.Fatalf("unexpected error: %v", change.Err)
+	}
+
+	if status := fsm.Reconnect(); status.Attempt != 0 {
+		t.Fatalf("reconnect status was not reset: %+v", status)
+	}
+}
+
+func TestFsm_CancelReconnect(t *testing.T) {
+	fsm := newTestFsm(t, FsmConfig{
+		Reconnect: ReconnectPolicy{
+			MaxAttempts:    5,
+			InitialBackoff: time.Hour,
+		},
+	})
+	changes := subscribe(t, fsm)
+
+	connect(t, fsm, fakeConfig(t, fakeRunner{mode: "crash-after-ready"}))
+
+	waitForState(t, changes, ReconnectingFsmState)
+
+	if status := fsm.Reconnect(); status.Attempt != 1 || status.MaxAttempts != 5 {
+		t.Fatalf("unexpected reconnect status: %+v", status)
+	}
+
+	disconnect(t, fsm)
+
+	waitForState(t, changes, DisconnectedFsmState)
+}
+
+func TestFsm_CancelConnect(t *testing.T) {
+	fsm := newTestFsm(t, FsmConfig{})
+	changes := subscribe(t, fsm)
+
+	config := fakeConfig(t, fakeRunner{mode: "slow", delay: time.Hour})
+	config.OptReadyTimeout = time.Hour
+
+	connect(t, fsm, config)
+
+	waitForState(t, changes, ConnectingFsmState)
+
+	start := time.Now()
+
+	disconnect(t, fsm)
+
+	change := waitForState(t, changes, DisconnectedFsmState)
+	if change.Cause != "connect cancelled" {
+		t.Fatalf("unexpected cause: %q", change.Cause)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("cancelling took %s", elapsed)
+	}
+
+	// The result of the cancelled attempt must not
+	// change the state once it arrives
+	time.Sleep(100 * time.Millisecond)
+
+	if state, _ := fsm.State(); state != DisconnectedFsmState {
+		t.Fatalf("state changed to %s after cancelling", state)
+	}
+}
+
+func TestFsm_StderrFlood(t *testing.T) {
+	const numLines = 2000
+
+	var notified atomic.Int32
+
+	fsm := newTestFsm(t, FsmConfig{
+		LogCapacity: numLines,
+		OnNewStderr: func(ctx context.Context) {
+			notified.Add(1)
+		},
+	})
+
+	connect(t, fsm, fakeConfig(t, fakeRunner{mode: "stderr-flood", lines: numLines}))
+
+	waitFor(t, "all log lines", func() bool {
+		return len(fsm.LogLines(0)) == numLines
+	})
+
+	lines := fsm.LogLines(0)
+	for i, line := range lines {
+		if line.Session != fsm.Session() {
+			t.Fatalf("line %d: session %d, want %d", i, line.Session, fsm.Session())
+		}
+
+		if i > 0 && line.Seq <= lines[i-1].Seq {
+			t.Fatalf("line %d: sequence numbers are not increasing", i)
+		}
+	}
+
+	if n := notified.Load(); n != numLines {
+		t.Fatalf("OnNewStderr called %d times, want %d", n, numLines)
+	}
+
+	// The Fsm must remain responsive
+	changes := subscribe(t, fsm)
+
+	disconnect(t, fsm)
+
+	waitForState(t, changes, DisconnectedFsmState)
+}
+
+func TestFsm_Status(t *testing.T) {
+	fsm := newTestFsm(t, FsmConfig{})
+
+	connect(t, fsm, fakeConfig(t, fakeRunner{mode: "status"}))
+
+	waitFor(t, "status", func() bool {
+		status, ok := fsm.Status()
+		return ok && status.PeerCount == 2
+	})
+
+	changes := subscribe(t, fsm)
+
+	disconnect(t, fsm)
+	waitForState(t, changes, DisconnectedFsmState)
+
+	// Reconnecting resets the status until wgu reports it again
+	connect(t, fsm, fakeConfig(t, fakeRunner{mode: "ready"}))
+	waitForState(t, changes, ConnectedFsmState)
+
+	if _, ok := fsm.Status(); ok {
+		t.Fatal("status was not reset")
+	}
+}
+
+func TestFsm_Destroy(t *testing.T) {
+	fsm := NewFsm(context.Background(), FsmConfig{})
+	changes := subscribe(t, fsm)
+
+	connect(t, fsm, fakeConfig(t, fakeRunner{mode: "ready"}))
+	waitForState(t, changes, ConnectedFsmState)
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), fsmTestTimeout)
+	defer cancelFn()
+
+	fsm.Destroy(ctx)
+
+	select {
+	case <-fsm.Done():
+	default:
+		t.Fatal("Fsm is not done after Destroy")
+	}
+}
+
+func TestFsm_RunningConfig(t *testing.T) {
+	fsm := newTestFsm(t, FsmConfig{})
+	changes := subscribe(t, fsm)
+
+	if _, ok := fsm.RunningConfig(); ok {
+		t.Fatal("got a running config while disconnected")
+	}
+
+	config := fakeConfig(t, fakeRunner{mode: "ready"})
+
+	connect(t, fsm, config)
+	waitForState(t, changes, ConnectedFsmState)
+
+	err := os.WriteFile(config.ConfigPath, []byte("[Interface]\nchanged\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	running, ok := fsm.RunningConfig()
+	if !ok || running != "[Interface]\n" {
+		t.Fatalf("got %q, %t - want the config wgu was started with", running, ok)
+	}
+
+	disconnect(t, fsm)
+	waitForState(t, changes, DisconnectedFsmState)
+
+	if _, ok := fsm.RunningConfig(); ok {
+		t.Fatal("got a running config after disconnecting")
+	}
+}
+
+func TestFsm_MoveConfig(t *testing.T) {
+	fsm := newTestFsm(t, FsmConfig{
+		Reconnect: ReconnectPolicy{
+			MaxAttempts:    1,
+			InitialBackoff: 200 * time.Millisecond,
+		},
+	})
+	changes := subscribe(t, fsm)
+
+	config := fakeConfig(t, fakeRunner{mode: "crash-after-ready", delay: 300 * time.Millisecond})
+
+	connect(t, fsm, config)
+	waitForState(t, changes, ConnectedFsmState)
+
+	movedPath := filepath.Join(filepath.Dir(config.ConfigPath), "moved.conf")
+
+	err := os.WriteFile(movedPath, []byte("[Interface]\nmoved\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Remove(config.ConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fsm.MoveConfig(context.Background(), movedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The reconnect attempt after the crash should use the new path
+	waitForState(t, changes, ReconnectingFsmState)
+	waitForState(t, changes, ConnectedFsmState)
+
+	running, ok := fsm.RunningConfig()
+	if !ok || running != "[Interface]\nmoved\n" {
+		t.Fatalf("got %q, %t - want the moved config", running, ok)
+	}
+}
@@ -4,13 +4,12 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"os/exec"
 	"strings"
 )
 
 // GetPublicKeyFromConfig extracts the public key from a WireGuard config file using wgu
 func GetPublicKeyFromConfig(ctx context.Context, config Config) (string, error) {
-	wguCmd := exec.CommandContext(ctx, config.GetExePath(), "pubkeyconf", config.ConfigPath)
+	wguCmd := config.getRunner().CommandContext(ctx, config.GetExePath(), "pubkeyconf", config.ConfigPath)
 
 	var stderr bytes.Buffer
 	wguCmd.Stderr = &stderr
@@ -28,7 +27,7 @@ func GetPublicKeyFromConfig(ctx context.Context, config Config) (string, error)
 
 // CreateConfig makes a default config file
 func CreateConfig(ctx context.Context, config Config, profileName string) error {
-	wguCmd := exec.CommandContext(ctx, config.ExePath, "genconf", "-n", profileName+".conf", config.ConfigPath)
+	wguCmd := config.getRunner().CommandContext(ctx, config.GetExePath(), "genconf", "-n", profileName+".conf", config.ConfigPath)
 
 	var stderr bytes.Buffer
 	wguCmd.Stderr = &stderr
@@ -41,3 +40,19 @@ func CreateConfig(ctx context.Context, config Config, profileName string) error
 
 	return nil
 }
+
+// GetVersion returns the version reported by wgu
+func GetVersion(ctx context.Context, config Config) (string, error) {
+	wguCmd := config.getRunner().CommandContext(ctx, config.GetExePath(), "version")
+
+	var stderr bytes.Buffer
+	wguCmd.Stderr = &stderr
+
+	output, err := wguCmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to execute '%s' - %w - stderr: '%s'",
+			wguCmd.String(), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,128 @@
+package wguctl
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultLogCapacity is the number of log lines kept per Fsm when
+// FsmConfig.LogCapacity is not set.
+const defaultLogCapacity = 5000
+
+// LogLine is a single line written to stderr by wgu.
+type LogLine struct {
+	// Seq increases by one for every line received, starting at 1.
+	// It keeps increasing after old lines are evicted, so it can be
+	// used to ask for only the lines that are new since a previous call.
+	Seq uint64
+
+	// Time is when wgui received the line.
+	Time time.Time
+
+	// Session identifies the connection attempt the line belongs to.
+	Session uint64
+
+	// Level is the log level wgu wrote the line with, if known.
+	Level LogLevel
+
+	Text string
+}
+
+// logBuffer is a fixed capacity ring buffer of log lines. Once full,
+// adding a line evicts the oldest one.
+type logBuffer struct {
+	rwMu    sync.RWMutex
+	lines   []LogLine
+	start   int
+	count   int
+	lastSeq uint64
+}
+
+func newLogBuffer(capacity int) *logBuffer {
+	if capacity <= 0 {
+		capacity = defaultLogCapacity
+	}
+
+	return &logBuffer{
+		lines: make([]LogLine, capacity),
+	}
+}
+
+// add appends line to the buffer, assigning its sequence number,
+// and returns the stored line.
+func (o *logBuffer) add(line LogLine) LogLine {
+	o.rwMu.Lock()
+	defer o.rwMu.Unlock()
+
+	o.lastSeq++
+	line.Seq = o.lastSeq
+
+	if o.count < len(o.lines) {
+		o.lines[(o.start+o.count)%len(o.lines)] = line
+		o.count++
+		return line
+	}
+
+	o.lines[o.start] = line
+	o.start = (o.start + 1) % len(o.lines)
+
+	return line
+}
+
+// since returns the buffered lines with a Seq greater than seq,
+// oldest first.
+func (o *logBuffer) since(seq uint64) []LogLine {
+	o.rwMu.RLock()
+	defer o.rwMu.RUnlock()
+
+	if seq >= o.lastSeq {
+		return nil
+	}
+
+	n := o.lastSeq - seq
+	if n > uint64(o.count) {
+		n = uint64(o.count)
+	}
+
+	lines := make([]LogLine, 0, n)
+	for i := o.count - int(n); i < o.count; i++ {
+		lines = append(lines, o.lines[(o.start+i)%len(o.lines)])
+	}
+
+	return lines
+}
+
+// bounds returns the sequence numbers of the oldest and newest
+// buffered lines. first is greater than last if there are none.
+func (o *logBuffer) bounds() (first uint64, last uint64) {
+	o.rwMu.RLock()
+	defer o.rwMu.RUnlock()
+
+	return o.lastSeq - uint64(o.count) + 1, o.lastSeq
+}
+
+// get returns the line with the given sequence number, or false
+// if it was evicted or has not been received yet.
+func (o *logBuffer) get(seq uint64) (LogLine, bool) {
+	o.rwMu.RLock()
+	defer o.rwMu.RUnlock()
+
+	first := o.lastSeq - uint64(o.count) + 1
+	if seq < first || seq > o.lastSeq {
+		return LogLine{}, false
+	}
+
+	return o.lines[(o.start+int(seq-first))%len(o.lines)], true
+}
+
+func (o *logBuffer) String() string {
+	var b strings.Builder
+
+	for _, line := range o.since(0) {
+		b.WriteString(line.Text)
+		b.WriteByte('\n')
+	}
+
+	return b.String()
+}
@@ -0,0 +1,120 @@
+package wguctl
+
+import (
+	"strconv"
+	"testing"
+)
+
+func addLines(buf *logBuffer, n int) {
+	for i := 0; i < n; i++ {
+		buf.add(LogLine{Text: "line " + strconv.Itoa(i+1)})
+	}
+}
+
+func TestLogBuffer_BoundsAndGet(t *testing.T) {
+	buf := newLogBuffer(3)
+
+	if first, last := buf.bounds(); first <= last {
+		t.Fatalf("got bounds %d-%d for an empty buffer", first, last)
+	}
+
+	if _, ok := buf.get(1); ok {
+		t.Fatal("got a line from an empty buffer")
+	}
+
+	addLines(buf, 5)
+
+	first, last := buf.bounds()
+	if first != 3 || last != 5 {
+		t.Fatalf("got bounds %d-%d, want 3-5", first, last)
+	}
+
+	for seq := first; seq <= last; seq++ {
+		line, ok := buf.get(seq)
+		if !ok || line.Seq != seq || line.Text != "line "+strconv.FormatUint(seq, 10) {
+			t.Fatalf("get(%d): got %+v, %t", seq, line, ok)
+		}
+	}
+
+	for _, seq := range []uint64{0, 2, 6} {
+		if _, ok := buf.get(seq); ok {
+			t.Fatalf("get(%d): expected no line", seq)
+		}
+	}
+}
+
+func TestLogBuffer_Wraparound(t *testing.T) {
+	buf := newLogBuffer(3)
+
+	addLines(buf, 7)
+
+	lines := buf.since(0)
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3", len(lines))
+	}
+
+	for i, line := range lines {
+		wantSeq := uint64(5 + i)
+		if line.Seq != wantSeq || line.Text != "line "+strconv.FormatUint(wantSeq, 10) {
+			t.Fatalf("line %d: got %+v, want seq %d", i, line, wantSeq)
+		}
+	}
+
+	if got, want := buf.String(), "line 5\nline 6\nline 7\n"; got != want {
+		t.Fatalf("String: got %q, want %q", got, want)
+	}
+}
+
+func TestLogBuffer_Since(t *testing.T) {
+	buf := newLogBuffer(4)
+
+	if lines := buf.since(0); len(lines) != 0 {
+		t.Fatalf("got %d lines from an empty buffer", len(lines))
+	}
+
+	addLines(buf, 6)
+
+	tests := []struct {
+		since     uint64
+		wantFirst uint64
+		wantCount int
+	}{
+		// The cursor was evicted, so all buffered lines are returned
+		{since: 0, wantFirst: 3, wantCount: 4},
+		{since: 1, wantFirst: 3, wantCount: 4},
+		{since: 2, wantFirst: 3, wantCount: 4},
+		{since: 4, wantFirst: 5, wantCount: 2},
+		{since: 6, wantCount: 0},
+		{since: 100, wantCount: 0},
+	}
+
+	for _, test := range tests {
+		lines := buf.since(test.since)
+		if len(lines) != test.wantCount {
+			t.Fatalf("since(%d): got %d lines, want %d", test.since, len(lines), test.wantCount)
+		}
+
+		for i, line := range lines {
+			if line.Seq != test.wantFirst+uint64(i) {
+				t.Fatalf("since(%d): line %d has seq %d, want %d",
+					test.since, i, line.Seq, test.wantFirst+uint64(i))
+			}
+		}
+	}
+}
+
+func TestLogBuffer_DefaultCapacity(t *testing.T) {
+	for _, capacity := range []int{0, -1} {
+		buf := newLogBuffer(capacity)
+
+		if len(buf.lines) != defaultLogCapacity {
+			t.Fatalf("capacity %d: got %d, want the default %d", capacity, len(buf.lines), defaultLogCapacity)
+		}
+
+		addLines(buf, defaultLogCapacity+1)
+
+		if first, last := buf.bounds(); first != 2 || last != defaultLogCapacity+1 {
+			t.Fatalf("capacity %d: got bounds %d-%d", capacity, first, last)
+		}
+	}
+}
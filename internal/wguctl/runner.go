@@ -0,0 +1,22 @@
+package wguctl
+
+import (
+	"context"
+	"os/exec"
+)
+
+// Runner creates the commands used to run wgu. It allows tests to
+// substitute a fake wgu, or to change the environment wgu runs in.
+type Runner interface {
+	// CommandContext returns a command that runs the named program
+	// with the given arguments. The process must be killed when
+	// ctx is done, like exec.CommandContext.
+	CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd
+}
+
+// ExecRunner is a Runner that runs programs with exec.CommandContext.
+type ExecRunner struct{}
+
+func (o ExecRunner) CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, name, args...)
+}
@@ -0,0 +1,62 @@
+package wguctl
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Status is what wgu reports about the tunnel on stdout after it
+// becomes ready.
+type Status struct {
+	ListenAddr string    `json:"listen_addr"`
+	PeerCount  int       `json:"peer_count"`
+	UpdatedAt  time.Time `json:"-"`
+}
+
+// stdoutMessage is a line of wgu's stdout protocol. wgu first writes
+// either the literal "ready" or a JSON object with type "ready". After
+// that it may write JSON objects with type "status" whenever the
+// tunnel's status changes. Fields of Status may be included in both.
+type stdoutMessage struct {
+	Type string `json:"type"`
+	Status
+}
+
+const (
+	readyStdoutMessageType  = "ready"
+	statusStdoutMessageType = "status"
+)
+
+// parseStdoutLine parses a line of wgu's stdout protocol. It returns
+// false if the line is not part of the protocol.
+func parseStdoutLine(line string) (stdoutMessage, bool) {
+	line = strings.TrimSpace(line)
+
+	if line == readyStdoutMessageType {
+		return stdoutMessage{Type: readyStdoutMessageType}, true
+	}
+
+	if !strings.HasPrefix(line, "{") {
+		return stdoutMessage{}, false
+	}
+
+	var msg stdoutMessage
+	err := json.Unmarshal([]byte(line), &msg)
+	if err != nil {
+		return stdoutMessage{}, false
+	}
+
+	switch msg.Type {
+	case readyStdoutMessageType, statusStdoutMessageType:
+		msg.UpdatedAt = time.Now()
+		return msg, true
+	default:
+		return stdoutMessage{}, false
+	}
+}
+
+// hasStatus reports whether the message carries any status fields.
+func (o stdoutMessage) hasStatus() bool {
+	return o.ListenAddr != "" || o.PeerCount != 0
+}
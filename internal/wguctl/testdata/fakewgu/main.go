@@ -0,0 +1,182 @@
+// fakewgu imitates the parts of wgu that wguctl relies on so that
+// wguctl can be tested without creating real tunnels.
+//
+// The behavior of "up" is selected with the FAKEWGU_MODE environment
+// variable:
+//
+//	ready             print "ready" and run until stopped (the default)
+//	slow              wait FAKEWGU_DELAY before printing "ready"
+//	crash-after-ready print "ready", then exit with code 1 after FAKEWGU_DELAY
+//	exit-before-ready exit with code 2 without printing "ready"
+//	garbage           print something other than "ready" and run until stopped
+//	stderr-flood      print "ready", then FAKEWGU_LINES lines to stderr
+//	status            print a JSON ready message and a status update
+//	stubborn          print "ready" and ignore requests to stop
+//	die-on-interrupt  print "ready" and ignore stdin closing, so that
+//	                  only the default interrupt handling stops it
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	modeEnv  = "FAKEWGU_MODE"
+	delayEnv = "FAKEWGU_DELAY"
+	linesEnv = "FAKEWGU_LINES"
+
+	fakePublicKey = "RmFrZVB1YmxpY0tleUZha2VQdWJsaWNLZXlGYWtlUHU="
+	fakeVersion   = "v0.0.0-fake"
+)
+
+func main() {
+	err := mainWithError()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fatal:", err)
+		os.Exit(1)
+	}
+}
+
+func mainWithError() error {
+	if len(os.Args) < 2 {
+		return fmt.Errorf("please specify a command")
+	}
+
+	switch cmd := os.Args[1]; cmd {
+	case "up":
+		return up()
+	case "pubkeyconf":
+		if len(os.Args) < 3 {
+			return fmt.Errorf("please specify a config file path")
+		}
+
+		_, err := os.Stat(os.Args[2])
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(fakePublicKey)
+		return nil
+	case "genconf":
+		// genconf -n <name> <dir>
+		if len(os.Args) < 5 || os.Args[2] != "-n" {
+			return fmt.Errorf("usage: genconf -n <name> <dir>")
+		}
+
+		return os.WriteFile(filepath.Join(os.Args[4], os.Args[3]),
+			[]byte("[Interface]\nPrivateKey = fake\n"), 0600)
+	case "version":
+		fmt.Println(fakeVersion)
+		return nil
+	default:
+		return fmt.Errorf("unknown command: %q", cmd)
+	}
+}
+
+func up() error {
+	delay, err := durationEnv(delayEnv, 100*time.Millisecond)
+	if err != nil {
+		return err
+	}
+
+	// Listen for stop requests before reporting ready,
+	// so that wgu can be stopped as soon as it is
+	stopped := stopRequested()
+
+	switch mode := os.Getenv(modeEnv); mode {
+	case "", "ready":
+		fmt.Println("ready")
+	case "slow":
+		time.Sleep(delay)
+		fmt.Println("ready")
+	case "crash-after-ready":
+		fmt.Println("ready")
+		time.Sleep(delay)
+		fmt.Fprintln(os.Stderr, "ERROR: 2006/01/02 15:04:05 device: something went wrong")
+		os.Exit(1)
+	case "exit-before-ready":
+		fmt.Fprintln(os.Stderr, "fatal: failed to parse config")
+		os.Exit(2)
+	case "garbage":
+		fmt.Println("\x00not ready\xff")
+	case "stderr-flood":
+		lines := 10000
+		if s := os.Getenv(linesEnv); s != "" {
+			lines, err = strconv.Atoi(s)
+			if err != nil {
+				return fmt.Errorf("failed to parse %s - %w", linesEnv, err)
+			}
+		}
+
+		fmt.Println("ready")
+
+		for i := 0; i < lines; i++ {
+			fmt.Fprintf(os.Stderr, "DEBUG: 2006/01/02 15:04:05 flood line %d\n", i)
+		}
+	case "status":
+		fmt.Println(`{"type":"ready","listen_addr":"127.0.0.1:51820","peer_count":1}`)
+		time.Sleep(delay)
+		fmt.Println(`{"type":"status","listen_addr":"127.0.0.1:51820","peer_count":2}`)
+	case "stubborn":
+		signal.Ignore(os.Interrupt)
+		fmt.Println("ready")
+		for {
+			time.Sleep(time.Hour)
+		}
+	case "die-on-interrupt":
+		signal.Reset(os.Interrupt)
+		fmt.Println("ready")
+		for {
+			time.Sleep(time.Hour)
+		}
+	default:
+		return fmt.Errorf("unknown %s: %q", modeEnv, mode)
+	}
+
+	<-stopped
+
+	return nil
+}
+
+// stopRequested returns a channel that receives a value when stdin
+// is closed or an interrupt is received, which is how wguctl asks
+// wgu to exit.
+func stopRequested() <-chan struct{} {
+	stop := make(chan struct{}, 2)
+
+	go func() {
+		_, _ = io.Copy(io.Discard, bufio.NewReader(os.Stdin))
+		stop <- struct{}{}
+	}()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+
+	go func() {
+		<-sigs
+		stop <- struct{}{}
+	}()
+
+	return stop
+}
+
+func durationEnv(name string, def time.Duration) (time.Duration, error) {
+	s := os.Getenv(name)
+	if s == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s - %w", name, err)
+	}
+
+	return d, nil
+}
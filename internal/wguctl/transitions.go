@@ -0,0 +1,223 @@
+package wguctl
+
+import (
+	"context"
+	"fmt"
+)
+
+// fsmInput identifies the kind of an fsmEvent.
+type fsmInput int
+
+const (
+	connectFsmInput fsmInput = iota
+	disconnectFsmInput
+	wguExitedFsmInput
+	retryFsmInput
+	connectResultFsmInput
+	moveConfigFsmInput
+)
+
+// fsmEvent is something that may cause the Fsm to change state.
+type fsmEvent interface {
+	input() fsmInput
+}
+
+type connectFsmEvent struct {
+	config Config
+}
+
+func (connectFsmEvent) input() fsmInput { return connectFsmInput }
+
+type disconnectFsmEvent struct{}
+
+func (disconnectFsmEvent) input() fsmInput { return disconnectFsmInput }
+
+// wguExitedFsmEvent occurs when the wgu process exits without
+// being asked to.
+type wguExitedFsmEvent struct{}
+
+func (wguExitedFsmEvent) input() fsmInput { return wguExitedFsmInput }
+
+// retryFsmEvent occurs when the backoff before a reconnect
+// attempt has elapsed.
+type retryFsmEvent struct{}
+
+func (retryFsmEvent) input() fsmInput { return retryFsmInput }
+
+// connectResultFsmEvent occurs when a connect attempt started by
+// startConnect finishes, successfully or not.
+type connectResultFsmEvent struct {
+	attempt int
+	wgu     *Wgu
+	err     error
+
+	// config is the contents of the config file
+	// when wgu was started, if it could be read.
+	config []byte
+}
+
+func (connectResultFsmEvent) input() fsmInput { return connectResultFsmInput }
+
+// moveConfigFsmEvent occurs when the config file was moved, so that
+// reconnect attempts use its new path.
+type moveConfigFsmEvent struct {
+	configPath string
+}
+
+func (moveConfigFsmEvent) input() fsmInput { return moveConfigFsmInput }
+
+// fsmHandler carries out a transition.
+type fsmHandler func(o *Fsm, ctx context.Context, event fsmEvent)
+
+// fsmTransitions lists the events each state accepts. Events that are
+// not listed for the current state are ignored. DisconnectingFsmState
+// is only entered while a handler runs, so it accepts no events.
+var fsmTransitions = map[FsmState]map[fsmInput]fsmHandler{
+	DisconnectedFsmState: {
+		connectFsmInput:    (*Fsm).handleConnect,
+		moveConfigFsmInput: (*Fsm).handleMoveConfig,
+	},
+	ConnectingFsmState: {
+		disconnectFsmInput:    (*Fsm).handleCancelConnect,
+		connectResultFsmInput: (*Fsm).handleConnectResult,
+		moveConfigFsmInput:    (*Fsm).handleMoveConfig,
+	},
+	ConnectedFsmState: {
+		disconnectFsmInput: (*Fsm).handleDisconnect,
+		wguExitedFsmInput:  (*Fsm).handleWguExit,
+		moveConfigFsmInput: (*Fsm).handleMoveConfig,
+	},
+	ErrorFsmState: {
+		connectFsmInput:    (*Fsm).handleConnect,
+		disconnectFsmInput: (*Fsm).handleClearError,
+		moveConfigFsmInput: (*Fsm).handleMoveConfig,
+	},
+	ReconnectingFsmState: {
+		connectFsmInput:    (*Fsm).handleConnect,
+		disconnectFsmInput: (*Fsm).handleCancelReconnect,
+		retryFsmInput:      (*Fsm).handleRetry,
+		moveConfigFsmInput: (*Fsm).handleMoveConfig,
+	},
+}
+
+// dispatch runs the handler for event in the current state, if any.
+func (o *Fsm) dispatch(ctx context.Context, event fsmEvent) {
+	state, _ := o.State()
+
+	handler, ok := fsmTransitions[state][event.input()]
+	if !ok {
+		// The result of a cancelled connect attempt may
+		// arrive after the Fsm moved on
+		if result, isResult := event.(connectResultFsmEvent); isResult && result.wgu != nil {
+			_ = result.wgu.Stop()
+		}
+
+		return
+	}
+
+	handler(o, ctx, event)
+}
+
+// handleConnect starts wgu with the requested config.
+func (o *Fsm) handleConnect(ctx context.Context, event fsmEvent) {
+	o.cancelRetry()
+	o.wguConfig = event.(connectFsmEvent).config
+
+	o.setState(ConnectingFsmState, nil, "connect requested")
+
+	o.startConnect(ctx, o.wguConfig)
+}
+
+// handleRetry makes the next reconnect attempt once its backoff
+// has elapsed.
+func (o *Fsm) handleRetry(ctx context.Context, _ fsmEvent) {
+	o.retryTimer = nil
+
+	o.setState(ConnectingFsmState, nil, fmt.Sprintf("reconnect attempt %d", o.Reconnect().Attempt))
+
+	o.startConnect(ctx, o.wguConfig)
+}
+
+// handleConnectResult finishes a connect attempt.
+func (o *Fsm) handleConnectResult(_ context.Context, event fsmEvent) {
+	result := event.(connectResultFsmEvent)
+	if result.attempt != o.connAttempt {
+		if result.wgu != nil {
+			_ = result.wgu.Stop()
+		}
+
+		return
+	}
+
+	if result.err != nil {
+		o.cancelConnect()
+
+		cause := "connect failed"
+		if attempt := o.Reconnect().Attempt; attempt > 0 {
+			cause = fmt.Sprintf("reconnect attempt %d failed", attempt)
+		}
+
+		o.failed(result.err, cause)
+		return
+	}
+
+	o.wgu = result.wgu
+
+	o.rwMutex.Lock()
+	o.runningConfig = result.config
+	o.rwMutex.Unlock()
+
+	o.connected("wgu is ready")
+}
+
+// handleCancelConnect aborts a connect attempt that is in progress.
+func (o *Fsm) handleCancelConnect(_ context.Context, _ fsmEvent) {
+	o.cancelConnect()
+
+	o.rwMutex.Lock()
+	o.reconnect = ReconnectStatus{}
+	o.setStateLocked(DisconnectedFsmState, nil, "connect cancelled")
+	o.rwMutex.Unlock()
+}
+
+// handleDisconnect stops the running wgu process.
+func (o *Fsm) handleDisconnect(ctx context.Context, _ fsmEvent) {
+	o.setState(DisconnectingFsmState, nil, "disconnect requested")
+
+	err := o.disconnect(ctx)
+	if err != nil {
+		o.setState(ErrorFsmState, err, "disconnect failed")
+		return
+	}
+
+	o.setState(DisconnectedFsmState, nil, "wgu stopped")
+}
+
+// handleWguExit transitions to the reconnecting or error state
+// when the wgu process exits without being asked to.
+func (o *Fsm) handleWguExit(_ context.Context, _ fsmEvent) {
+	err := o.wgu.unexpectedExitErr()
+	o.wgu = nil
+	o.cancelConnect()
+
+	o.failed(err, "wgu exited")
+}
+
+// handleCancelReconnect cancels a pending reconnect attempt.
+func (o *Fsm) handleCancelReconnect(_ context.Context, _ fsmEvent) {
+	o.cancelRetry()
+
+	o.setState(DisconnectedFsmState, nil, "reconnect cancelled")
+}
+
+// handleMoveConfig updates the path of the config file that
+// reconnect attempts start wgu with, without changing state.
+func (o *Fsm) handleMoveConfig(_ context.Context, event fsmEvent) {
+	o.wguConfig.ConfigPath = event.(moveConfigFsmEvent).configPath
+}
+
+// handleClearError acknowledges an error, returning to the
+// disconnected state.
+func (o *Fsm) handleClearError(_ context.Context, _ fsmEvent) {
+	o.setState(DisconnectedFsmState, nil, "error cleared")
+}
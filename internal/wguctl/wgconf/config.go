@@ -0,0 +1,190 @@
+package wgconf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	InterfaceSection = "Interface"
+	PeerSection      = "Peer"
+)
+
+// Config is the typed form of a config.
+type Config struct {
+	Interface Interface
+	Peers     []Peer
+
+	// Other is the sections that are not part of WireGuard's
+	// config format, such as wgu's own sections. Their format
+	// is up to wgu, so they are kept as they are.
+	Other []*Section
+}
+
+// Interface is the [Interface] section.
+type Interface struct {
+	PrivateKey string
+	ListenPort int
+	Addresses  []string
+	DNS        []string
+	MTU        int
+
+	// Section is the section the Interface was decoded from.
+	Section *Section
+}
+
+// Peer is a [Peer] section.
+type Peer struct {
+	PublicKey           string
+	PresharedKey        string
+	Endpoint            string
+	AllowedIPs          []string
+	PersistentKeepalive int
+
+	// Section is the section the Peer was decoded from.
+	Section *Section
+}
+
+// ParseConfig parses a config and decodes it.
+func ParseConfig(data []byte) (*File, *Config, error) {
+	file, err := Parse(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config, err := file.Decode()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return file, config, nil
+}
+
+// Decode decodes the [Interface] and [Peer] sections of the config.
+// Other sections are returned as they are and unknown keys are
+// ignored. The returned error is a *ParseError if a
+// value is malformed or there is more than one [Interface] section.
+func (o *File) Decode() (*Config, error) {
+	var config Config
+
+	interfaces := o.SectionsNamed(InterfaceSection)
+	if len(interfaces) > 1 {
+		return nil, &ParseError{
+			Line:   interfaces[1].Line(),
+			Column: 1,
+			Msg:    fmt.Sprintf("only one [%s] section is allowed", InterfaceSection),
+		}
+	}
+
+	for _, section := range o.Sections {
+		var err error
+
+		switch {
+		case section.Is(InterfaceSection):
+			config.Interface, err = decodeInterface(section)
+		case section.Is(PeerSection):
+			var peer Peer
+			peer, err = decodePeer(section)
+			config.Peers = append(config.Peers, peer)
+		default:
+			config.Other = append(config.Other, section)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &config, nil
+}
+
+func decodeInterface(section *Section) (Interface, error) {
+	iface := Interface{
+		Section: section,
+	}
+
+	for _, line := range section.Entries() {
+		var err error
+
+		switch strings.ToLower(line.Key) {
+		case "privatekey":
+			iface.PrivateKey = line.Value
+		case "listenport":
+			iface.ListenPort, err = decodeInt(line, 0, 65535)
+		case "address":
+			iface.Addresses = append(iface.Addresses, splitList(line.Value)...)
+		case "dns":
+			iface.DNS = append(iface.DNS, splitList(line.Value)...)
+		case "mtu":
+			iface.MTU, err = decodeInt(line, 0, 65535)
+		}
+
+		if err != nil {
+			return Interface{}, err
+		}
+	}
+
+	return iface, nil
+}
+
+func decodePeer(section *Section) (Peer, error) {
+	peer := Peer{
+		Section: section,
+	}
+
+	for _, line := range section.Entries() {
+		var err error
+
+		switch strings.ToLower(line.Key) {
+		case "publickey":
+			peer.PublicKey = line.Value
+		case "presharedkey":
+			peer.PresharedKey = line.Value
+		case "endpoint":
+			peer.Endpoint = line.Value
+		case "allowedips":
+			peer.AllowedIPs = append(peer.AllowedIPs, splitList(line.Value)...)
+		case "persistentkeepalive":
+			if strings.EqualFold(line.Value, "off") {
+				continue
+			}
+
+			peer.PersistentKeepalive, err = decodeInt(line, 0, 65535)
+		}
+
+		if err != nil {
+			return Peer{}, err
+		}
+	}
+
+	return peer, nil
+}
+
+func decodeInt(line *Line, min int, max int) (int, error) {
+	i, err := strconv.Atoi(line.Value)
+	if err != nil || i < min || i > max {
+		return 0, &ParseError{
+			Line:   line.Num,
+			Column: line.ValueColumn,
+			Msg: fmt.Sprintf("%s must be a number from %d to %d - got %q",
+				line.Key, min, max, line.Value),
+		}
+	}
+
+	return i, nil
+}
+
+// splitList splits a comma-separated value.
+func splitList(value string) []string {
+	var items []string
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
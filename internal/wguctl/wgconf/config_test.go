@@ -0,0 +1,72 @@
+package wgconf
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	_, config, err := ParseConfig([]byte(exampleConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Interface.ListenPort != 51820 {
+		t.Fatalf("ListenPort: got %d", config.Interface.ListenPort)
+	}
+
+	if got := config.Interface.Addresses; len(got) != 1 || got[0] != "10.0.0.1/24" {
+		t.Fatalf("Addresses: got %v", got)
+	}
+
+	if n := len(config.Peers); n != 1 {
+		t.Fatalf("got %d peers, want 1", n)
+	}
+
+	wantIPs := []string{"10.0.0.2/32", "10.0.1.0/24", "10.0.2.0/24"}
+	if got := config.Peers[0].AllowedIPs; len(got) != len(wantIPs) {
+		t.Fatalf("AllowedIPs: got %v, want %v", got, wantIPs)
+	}
+
+	if n := len(config.Other); n != 1 || !config.Other[0].Is("Custom") {
+		t.Fatalf("unexpected other sections: %+v", config.Other)
+	}
+}
+
+func TestParseConfig_Errors(t *testing.T) {
+	tests := []struct {
+		config string
+		line   int
+		column int
+	}{
+		{config: "[Interface]\nListenPort = abc", line: 2, column: 14},
+		{config: "[Interface]\nListenPort=99999", line: 2, column: 12},
+		{config: "[Peer]\nPersistentKeepalive = -1", line: 2, column: 23},
+		{config: "[Interface]\n[Interface]", line: 2, column: 1},
+	}
+
+	for _, test := range tests {
+		_, _, err := ParseConfig([]byte(test.config))
+
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("%q: expected a ParseError - got: %v", test.config, err)
+		}
+
+		if parseErr.Line != test.line || parseErr.Column != test.column {
+			t.Fatalf("%q: got line %d column %d, want line %d column %d - %v",
+				test.config, parseErr.Line, parseErr.Column, test.line, test.column, err)
+		}
+	}
+}
+
+func TestParseConfig_KeepaliveOff(t *testing.T) {
+	_, config, err := ParseConfig([]byte("[Peer]\nPersistentKeepalive = off\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Peers[0].PersistentKeepalive != 0 {
+		t.Fatalf("got %d, want 0", config.Peers[0].PersistentKeepalive)
+	}
+}
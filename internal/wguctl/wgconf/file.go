@@ -0,0 +1,462 @@
+// Package wgconf parses and edits the INI-style configs used by
+// WireGuard and wgu without running wgu.
+//
+// A config is parsed into a File, which keeps every line, comment
+// and blank line in order so that it can be edited and written back
+// without losing anything. A File can be decoded into a typed Config.
+package wgconf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// LineKind identifies what a Line contains.
+type LineKind int
+
+const (
+	BlankLine LineKind = iota
+	CommentLine
+	SectionLine
+	EntryLine
+)
+
+// Line is a line of a config.
+type Line struct {
+	Kind LineKind
+
+	// Num is the 1-based line number the line was parsed from,
+	// or 0 if the line was added after parsing.
+	Num int
+
+	// Key and Value are only set for EntryLine.
+	Key   string
+	Value string
+
+	// ValueColumn is the 1-based column at which Value starts.
+	// It is only set for EntryLine.
+	ValueColumn int
+
+	raw string
+
+	// newline is the line ending the line was parsed with. It is
+	// empty for lines added after parsing and for a last line that
+	// did not end with a newline.
+	newline string
+}
+
+// String returns the line as it is written in the config.
+func (o *Line) String() string {
+	return o.raw
+}
+
+// Section is a section of a config, such as [Interface] or [Peer],
+// along with the lines that follow its header.
+type Section struct {
+	// Name is the name in the section's header, as written.
+	Name string
+
+	header *Line
+	lines  []*Line
+}
+
+// Is reports whether the section has the given name. Section
+// names are not case-sensitive.
+func (o *Section) Is(name string) bool {
+	return strings.EqualFold(o.Name, name)
+}
+
+// Line returns the line number of the section's header.
+func (o *Section) Line() int {
+	return o.header.Num
+}
+
+// Lines returns the lines of the section, not including its header.
+func (o *Section) Lines() []*Line {
+	return o.lines
+}
+
+// Entries returns the key-value lines of the section in order.
+func (o *Section) Entries() []*Line {
+	var entries []*Line
+
+	for _, line := range o.lines {
+		if line.Kind == EntryLine {
+			entries = append(entries, line)
+		}
+	}
+
+	return entries
+}
+
+// Get returns the value of the first entry with the given key.
+// Keys are not case-sensitive.
+func (o *Section) Get(key string) (string, bool) {
+	line := o.entry(key)
+	if line == nil {
+		return "", false
+	}
+
+	return line.Value, true
+}
+
+// GetAll returns the values of every entry with the given key.
+func (o *Section) GetAll(key string) []string {
+	var values []string
+
+	for _, line := range o.lines {
+		if line.Kind == EntryLine && strings.EqualFold(line.Key, key) {
+			values = append(values, line.Value)
+		}
+	}
+
+	return values
+}
+
+// Set changes the value of the first entry with the given key, or
+// adds an entry after the section's last entry if there is none.
+func (o *Section) Set(key string, value string) {
+	line := o.entry(key)
+	if line != nil {
+		line.Value = value
+		line.raw = formatEntry(line.Key, value)
+		return
+	}
+
+	newLine := &Line{
+		Kind:  EntryLine,
+		Key:   key,
+		Value: value,
+		raw:   formatEntry(key, value),
+	}
+
+	i := len(o.lines)
+	for i > 0 && o.lines[i-1].Kind != EntryLine {
+		i--
+	}
+
+	o.lines = append(o.lines[:i], append([]*Line{newLine}, o.lines[i:]...)...)
+}
+
+// Delete removes every entry with the given key.
+func (o *Section) Delete(key string) {
+	lines := o.lines[:0]
+
+	for _, line := range o.lines {
+		if line.Kind == EntryLine && strings.EqualFold(line.Key, key) {
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	o.lines = lines
+}
+
+func (o *Section) entry(key string) *Line {
+	for _, line := range o.lines {
+		if line.Kind == EntryLine && strings.EqualFold(line.Key, key) {
+			return line
+		}
+	}
+
+	return nil
+}
+
+func formatEntry(key string, value string) string {
+	return key + " = " + value
+}
+
+// File is a parsed config.
+type File struct {
+	// Preamble is the comments and blank lines that
+	// come before the first section.
+	Preamble []*Line
+
+	Sections []*Section
+
+	// newline is the line ending used for lines that were added
+	// after parsing. It is the first line ending in the config.
+	newline string
+
+	// noFinalNewline is true if the config's last line did not
+	// end with a newline.
+	noFinalNewline bool
+}
+
+// SectionsNamed returns the sections with the given name in order.
+func (o *File) SectionsNamed(name string) []*Section {
+	var sections []*Section
+
+	for _, section := range o.Sections {
+		if section.Is(name) {
+			sections = append(sections, section)
+		}
+	}
+
+	return sections
+}
+
+// AddSection appends a new, empty section to the config.
+func (o *File) AddSection(name string) *Section {
+	section := &Section{
+		Name: name,
+		header: &Line{
+			Kind: SectionLine,
+			raw:  "[" + name + "]",
+		},
+	}
+
+	if len(o.Sections) > 0 {
+		last := o.Sections[len(o.Sections)-1]
+		if n := len(last.lines); n == 0 || last.lines[n-1].Kind != BlankLine {
+			last.lines = append(last.lines, &Line{Kind: BlankLine})
+		}
+	}
+
+	o.Sections = append(o.Sections, section)
+
+	return section
+}
+
+// RemoveSection removes a section from the config.
+func (o *File) RemoveSection(section *Section) {
+	for i, s := range o.Sections {
+		if s == section {
+			o.Sections = append(o.Sections[:i], o.Sections[i+1:]...)
+			return
+		}
+	}
+}
+
+// Bytes returns the config as it should be written to a file.
+// Lines that were not changed are written exactly as they were
+// parsed, including their line endings.
+func (o *File) Bytes() []byte {
+	defaultNewline := o.newline
+	if defaultNewline == "" {
+		defaultNewline = "\n"
+	}
+
+	lines := o.lines()
+
+	buf := bytes.NewBuffer(nil)
+
+	for i, line := range lines {
+		buf.WriteString(line.raw)
+
+		if i == len(lines)-1 && o.noFinalNewline {
+			break
+		}
+
+		if line.newline != "" {
+			buf.WriteString(line.newline)
+		} else {
+			buf.WriteString(defaultNewline)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// lines returns every line of the config in order.
+func (o *File) lines() []*Line {
+	lines := append([]*Line(nil), o.Preamble...)
+
+	for _, section := range o.Sections {
+		lines = append(lines, section.header)
+		lines = append(lines, section.lines...)
+	}
+
+	return lines
+}
+
+// String returns the config as a string.
+func (o *File) String() string {
+	return string(o.Bytes())
+}
+
+// ParseError describes a problem at a specific place in a config.
+type ParseError struct {
+	// Line and Column are 1-based.
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (o *ParseError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", o.Line, o.Column, o.Msg)
+}
+
+// Parse parses a config. The returned error is a *ParseError
+// if the config is malformed.
+func Parse(data []byte) (*File, error) {
+	file := &File{
+		noFinalNewline: len(data) > 0 && data[len(data)-1] != '\n',
+	}
+
+	var current *Section
+
+	lineNum := 0
+
+	for len(data) > 0 {
+		lineNum++
+
+		var raw []byte
+		var newline string
+
+		raw, data, newline = splitLine(data)
+
+		if file.newline == "" {
+			file.newline = newline
+		}
+
+		line, err := parseLine(string(raw), lineNum)
+		if err != nil {
+			return nil, err
+		}
+
+		line.newline = newline
+
+		switch line.Kind {
+		case SectionLine:
+			current = &Section{
+				Name:   line.Key,
+				header: line,
+			}
+
+			line.Key = ""
+
+			file.Sections = append(file.Sections, current)
+		case EntryLine:
+			if current == nil {
+				return nil, &ParseError{
+					Line:   lineNum,
+					Column: 1,
+					Msg:    fmt.Sprintf("%q is not in a section", line.Key),
+				}
+			}
+
+			current.lines = append(current.lines, line)
+		default:
+			if current == nil {
+				file.Preamble = append(file.Preamble, line)
+			} else {
+				current.lines = append(current.lines, line)
+			}
+		}
+	}
+
+	return file, nil
+}
+
+// splitLine splits the first line off data, returning the line
+// without its line ending, the rest of data and the line ending.
+func splitLine(data []byte) ([]byte, []byte, string) {
+	end := bytes.IndexByte(data, '\n')
+	if end < 0 {
+		return data, nil, ""
+	}
+
+	line, rest := data[:end], data[end+1:]
+
+	if bytes.HasSuffix(line, []byte("\r")) {
+		return line[:len(line)-1], rest, "\r\n"
+	}
+
+	return line, rest, "\n"
+}
+
+// parseLine parses a single line. For section headers, the
+// section name is returned in Key.
+func parseLine(raw string, lineNum int) (*Line, error) {
+	line := &Line{
+		Num: lineNum,
+		raw: raw,
+	}
+
+	trimmed := strings.TrimSpace(raw)
+	indent := strings.Index(raw, trimmed)
+
+	switch {
+	case trimmed == "":
+		line.Kind = BlankLine
+		return line, nil
+	case strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";"):
+		line.Kind = CommentLine
+		return line, nil
+	case strings.HasPrefix(trimmed, "["):
+		content := stripComment(trimmed)
+
+		end := strings.Index(content, "]")
+		if end < 0 {
+			return nil, &ParseError{
+				Line:   lineNum,
+				Column: indent + len(content) + 1,
+				Msg:    "section header is missing ']'",
+			}
+		}
+
+		if rest := strings.TrimSpace(content[end+1:]); rest != "" {
+			return nil, &ParseError{
+				Line:   lineNum,
+				Column: indent + strings.Index(content, rest) + 1,
+				Msg:    fmt.Sprintf("unexpected %q after section header", rest),
+			}
+		}
+
+		name := strings.TrimSpace(content[1:end])
+		if name == "" {
+			return nil, &ParseError{
+				Line:   lineNum,
+				Column: indent + 2,
+				Msg:    "section name is empty",
+			}
+		}
+
+		line.Kind = SectionLine
+		line.Key = name
+		return line, nil
+	}
+
+	equals := strings.Index(raw, "=")
+	if equals < 0 {
+		return nil, &ParseError{
+			Line:   lineNum,
+			Column: indent + 1,
+			Msg:    fmt.Sprintf("expected 'key = value' - got %q", trimmed),
+		}
+	}
+
+	key := strings.TrimSpace(raw[:equals])
+	if key == "" {
+		return nil, &ParseError{
+			Line:   lineNum,
+			Column: indent + 1,
+			Msg:    "key is empty",
+		}
+	}
+
+	afterEquals := raw[equals+1:]
+	value := strings.TrimSpace(stripComment(afterEquals))
+
+	line.Kind = EntryLine
+	line.Key = key
+	line.Value = value
+	line.ValueColumn = equals + 2
+	if value != "" {
+		line.ValueColumn += strings.Index(afterEquals, value)
+	}
+
+	return line, nil
+}
+
+// stripComment removes a trailing '#' comment.
+func stripComment(s string) string {
+	if i := strings.Index(s, "#"); i >= 0 {
+		return s[:i]
+	}
+
+	return s
+}
@@ -0,0 +1,194 @@
+package wgconf
+
+import (
+	"errors"
+	"testing"
+)
+
+const exampleConfig = `# Example config
+# with a preamble
+
+[Interface]
+PrivateKey = cHJpdmF0ZWtleXByaXZhdGVrZXlwcml2YXRla2V5MDA=
+ListenPort=51820   # inline comment
+Address = 10.0.0.1/24
+
+; another comment style
+[Peer]
+PublicKey = cHVibGlja2V5cHVibGlja2V5cHVibGlja2V5cHViMDA=
+AllowedIPs = 10.0.0.2/32, 10.0.1.0/24
+AllowedIPs = 10.0.2.0/24
+
+[Custom]
+Foo = bar
+`
+
+func TestParse_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{name: "example", config: exampleConfig},
+		{name: "empty", config: ""},
+		{name: "blank lines", config: "\n\n"},
+		{name: "no trailing newline", config: "[Interface]"},
+		{name: "no trailing newline after entries", config: "[Interface]\nPrivateKey = x"},
+		{name: "crlf", config: "[Interface]\r\nPrivateKey = x\r\n\r\n[Peer]\r\n"},
+		{name: "crlf without trailing newline", config: "[Interface]\r\nPrivateKey = x"},
+		{name: "mixed endings", config: "[Interface]\r\nPrivateKey = x\n\r\n[Peer]\nPublicKey = y\r\n"},
+		{name: "comments only", config: "# a comment\n; another comment\n"},
+		{name: "comment without trailing newline", config: "# a comment"},
+		{name: "whitespace", config: "  [ Interface ]  \n\tKey   =   value with spaces   \n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file, err := Parse([]byte(test.config))
+			if err != nil {
+				t.Fatalf("failed to parse %q - %v", test.config, err)
+			}
+
+			if got := file.String(); got != test.config {
+				t.Fatalf("round trip changed config\ngot:  %q\nwant: %q", got, test.config)
+			}
+		})
+	}
+}
+
+func TestFile_EditKeepsLineEndings(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{
+			name:   "crlf",
+			config: "[Interface]\r\nPrivateKey = x\r\n",
+			want:   "[Interface]\r\nPrivateKey = x\r\nMTU = 1420\r\n\r\n[Peer]\r\nPublicKey = y\r\n",
+		},
+		{
+			name:   "mixed endings",
+			config: "[Interface]\nPrivateKey = x\r\n",
+			want:   "[Interface]\nPrivateKey = x\r\nMTU = 1420\n\n[Peer]\nPublicKey = y\n",
+		},
+		{
+			name:   "no trailing newline",
+			config: "[Interface]\r\nPrivateKey = x",
+			want:   "[Interface]\r\nPrivateKey = x\r\nMTU = 1420\r\n\r\n[Peer]\r\nPublicKey = y",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file, err := Parse([]byte(test.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			file.SectionsNamed("Interface")[0].Set("MTU", "1420")
+			file.AddSection("Peer").Set("PublicKey", "y")
+
+			if got := file.String(); got != test.want {
+				t.Fatalf("got:  %q\nwant: %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParse_Structure(t *testing.T) {
+	file, err := Parse([]byte(exampleConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(file.Preamble); n != 3 {
+		t.Fatalf("preamble: got %d lines, want 3", n)
+	}
+
+	if n := len(file.Sections); n != 3 {
+		t.Fatalf("got %d sections, want 3", n)
+	}
+
+	iface := file.SectionsNamed("interface")[0]
+	if iface.Line() != 4 {
+		t.Fatalf("interface line: got %d, want 4", iface.Line())
+	}
+
+	port, ok := iface.Get("LISTENPORT")
+	if !ok || port != "51820" {
+		t.Fatalf("ListenPort: got %q, %t", port, ok)
+	}
+
+	peer := file.SectionsNamed("Peer")[0]
+	if got := peer.GetAll("AllowedIPs"); len(got) != 2 {
+		t.Fatalf("AllowedIPs: got %v", got)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		config string
+		line   int
+		column int
+	}{
+		{config: "[Interface", line: 1, column: 11},
+		{config: "[]", line: 1, column: 2},
+		{config: "[Interface] extra", line: 1, column: 13},
+		{config: "Key = value", line: 1, column: 1},
+		{config: "[Interface]\n  no equals sign", line: 2, column: 3},
+		{config: "[Interface]\n = value", line: 2, column: 2},
+	}
+
+	for _, test := range tests {
+		_, err := Parse([]byte(test.config))
+
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("%q: expected a ParseError - got: %v", test.config, err)
+		}
+
+		if parseErr.Line != test.line || parseErr.Column != test.column {
+			t.Fatalf("%q: got line %d column %d, want line %d column %d - %v",
+				test.config, parseErr.Line, parseErr.Column, test.line, test.column, err)
+		}
+	}
+}
+
+func TestSection_Edit(t *testing.T) {
+	file, err := Parse([]byte(exampleConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iface := file.SectionsNamed("Interface")[0]
+	iface.Set("ListenPort", "1234")
+	iface.Set("MTU", "1420")
+	iface.Delete("Address")
+
+	peer := file.AddSection("Peer")
+	peer.Set("PublicKey", "abc")
+
+	file.RemoveSection(file.SectionsNamed("Custom")[0])
+
+	want := `# Example config
+# with a preamble
+
+[Interface]
+PrivateKey = cHJpdmF0ZWtleXByaXZhdGVrZXlwcml2YXRla2V5MDA=
+ListenPort = 1234
+MTU = 1420
+
+; another comment style
+[Peer]
+PublicKey = cHVibGlja2V5cHVibGlja2V5cHVibGlja2V5cHViMDA=
+AllowedIPs = 10.0.0.2/32, 10.0.1.0/24
+AllowedIPs = 10.0.2.0/24
+
+[Peer]
+PublicKey = abc
+`
+
+	if got := file.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
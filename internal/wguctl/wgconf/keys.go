@@ -0,0 +1,86 @@
+package wgconf
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+// ParseKey decodes a base64 WireGuard key.
+func ParseKey(key string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("key is not valid base64 - %w", err)
+	}
+
+	if len(b) != keyLen {
+		return nil, fmt.Errorf("key must be %d bytes long - got %d", keyLen, len(b))
+	}
+
+	return b, nil
+}
+
+// PublicKey derives the base64 public key of a base64 private key.
+func PublicKey(privateKey string) (string, error) {
+	b, err := ParseKey(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse private key - %w", err)
+	}
+
+	// X25519 clamps the private key itself, so keys
+	// that were not clamped when generated still work
+	priv, err := ecdh.X25519().NewPrivateKey(b)
+	if err != nil {
+		return "", fmt.Errorf("failed to load private key - %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(priv.PublicKey().Bytes()), nil
+}
+
+// GeneratePrivateKey returns a new base64 private key,
+// like 'wg genkey'.
+func GeneratePrivateKey() (string, error) {
+	b, err := randomKey()
+	if err != nil {
+		return "", err
+	}
+
+	// Clamp the key as described in RFC 7748
+	b[0] &= 248
+	b[31] &= 127
+	b[31] |= 64
+
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// GeneratePresharedKey returns a new base64 preshared key,
+// like 'wg genpsk'.
+func GeneratePresharedKey() (string, error) {
+	b, err := randomKey()
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+func randomKey() ([]byte, error) {
+	b := make([]byte, keyLen)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read random bytes - %w", err)
+	}
+
+	return b, nil
+}
+
+// PublicKey derives the public key of the config's [Interface].
+func (o *Config) PublicKey() (string, error) {
+	if o.Interface.PrivateKey == "" {
+		return "", fmt.Errorf("config has no [%s] PrivateKey", InterfaceSection)
+	}
+
+	return PublicKey(o.Interface.PrivateKey)
+}
@@ -0,0 +1,72 @@
+package wgconf
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestPublicKey(t *testing.T) {
+	// Test vector from RFC 7748 section 6.1
+	private := "dwdtCnMYpX08FsFyUbJmRd9ML4frwJkqsXf7pR25LCo="
+	want := "hSDwCYkwp1R0i33ctD73Wg2/Og0mOBr066SpjqqbTmo="
+
+	got, err := PublicKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestPublicKey_Invalid(t *testing.T) {
+	for _, key := range []string{"", "not base64!", "YWJj"} {
+		_, err := PublicKey(key)
+		if err == nil {
+			t.Fatalf("%q: expected an error", key)
+		}
+	}
+}
+
+func TestGeneratePrivateKey(t *testing.T) {
+	key, err := GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b[0]&7 != 0 || b[31]&128 != 0 || b[31]&64 == 0 {
+		t.Fatalf("key is not clamped: %x", b)
+	}
+
+	_, err = PublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key == other {
+		t.Fatal("generated the same key twice")
+	}
+}
+
+func TestGeneratePresharedKey(t *testing.T) {
+	key, err := GeneratePresharedKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ParseKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
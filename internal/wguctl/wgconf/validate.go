@@ -0,0 +1,320 @@
+package wgconf
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Severity is how serious a Diagnostic is.
+type Severity int
+
+const (
+	// WarningSeverity is for problems that wgu may tolerate,
+	// such as keys it does not know about.
+	WarningSeverity Severity = iota
+
+	// ErrorSeverity is for problems that stop wgu from starting.
+	ErrorSeverity
+)
+
+func (o Severity) String() string {
+	switch o {
+	case WarningSeverity:
+		return "warning"
+	case ErrorSeverity:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// Diagnostic is a problem found in a config by Validate.
+type Diagnostic struct {
+	Severity Severity
+
+	// Line and Column are 1-based.
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (o Diagnostic) String() string {
+	return fmt.Sprintf("line %d, column %d: %s: %s", o.Line, o.Column, o.Severity, o.Msg)
+}
+
+// HasErrors reports whether any of the diagnostics is an error.
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity == ErrorSeverity {
+			return true
+		}
+	}
+
+	return false
+}
+
+// keyLen is the length of a decoded WireGuard key.
+const keyLen = 32
+
+// knownKeys lists the keys wgu understands in the sections
+// of WireGuard's config format.
+var knownKeys = map[string][]string{
+	InterfaceSection: {"PrivateKey", "ListenPort", "Address", "DNS", "MTU"},
+	PeerSection:      {"PublicKey", "PresharedKey", "Endpoint", "AllowedIPs", "PersistentKeepalive"},
+}
+
+// Validate checks a config for problems that would stop wgu from
+// starting, and for things that are likely mistakes. The returned
+// diagnostics are sorted by position.
+func Validate(data []byte) []Diagnostic {
+	file, err := Parse(data)
+	if err != nil {
+		return []Diagnostic{diagnosticFromErr(err)}
+	}
+
+	v := &validator{}
+
+	v.validate(file)
+
+	sort.SliceStable(v.diagnostics, func(i, j int) bool {
+		a, b := v.diagnostics[i], v.diagnostics[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+
+		return a.Column < b.Column
+	})
+
+	return v.diagnostics
+}
+
+func diagnosticFromErr(err error) Diagnostic {
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		return Diagnostic{
+			Severity: ErrorSeverity,
+			Line:     parseErr.Line,
+			Column:   parseErr.Column,
+			Msg:      parseErr.Msg,
+		}
+	}
+
+	return Diagnostic{
+		Severity: ErrorSeverity,
+		Line:     1,
+		Column:   1,
+		Msg:      err.Error(),
+	}
+}
+
+type validator struct {
+	diagnostics []Diagnostic
+}
+
+func (o *validator) add(severity Severity, line int, column int, format string, a ...any) {
+	o.diagnostics = append(o.diagnostics, Diagnostic{
+		Severity: severity,
+		Line:     line,
+		Column:   column,
+		Msg:      fmt.Sprintf(format, a...),
+	})
+}
+
+func (o *validator) validate(file *File) {
+	interfaces := file.SectionsNamed(InterfaceSection)
+	switch {
+	case len(interfaces) == 0:
+		o.add(ErrorSeverity, 1, 1, "missing [%s] section", InterfaceSection)
+	case len(interfaces) > 1:
+		for _, section := range interfaces[1:] {
+			o.add(ErrorSeverity, section.Line(), 1, "only one [%s] section is allowed", InterfaceSection)
+		}
+	}
+
+	peersByKey := make(map[string]int)
+
+	for _, section := range file.Sections {
+		o.validateKeys(section)
+
+		switch {
+		case section.Is(InterfaceSection):
+			o.validateInterface(section)
+		case section.Is(PeerSection):
+			o.validatePeer(section, peersByKey)
+		}
+	}
+}
+
+// validateKeys warns about unknown keys in the sections of
+// WireGuard's config format. Other sections, such as wgu's own,
+// are left for wgu to check.
+func (o *validator) validateKeys(section *Section) {
+	var known []string
+	for name, keys := range knownKeys {
+		if section.Is(name) {
+			known = keys
+		}
+	}
+
+	if known == nil {
+		return
+	}
+
+	for _, line := range section.Entries() {
+		if !containsFold(known, line.Key) {
+			o.add(WarningSeverity, line.Num, keyColumn(line), "unknown key %q in [%s]", line.Key, section.Name)
+		}
+	}
+}
+
+func (o *validator) validateInterface(section *Section) {
+	if _, ok := section.Get("PrivateKey"); !ok {
+		o.add(ErrorSeverity, section.Line(), 1, "[%s] is missing PrivateKey", section.Name)
+	}
+
+	for _, line := range section.Entries() {
+		switch strings.ToLower(line.Key) {
+		case "privatekey":
+			o.validateKey(line)
+		case "listenport", "mtu":
+			o.validateInt(line)
+		case "address":
+			o.validateList(line, validatePrefixOrAddr)
+		case "dns":
+			o.validateList(line, func(item string) error {
+				_, err := netip.ParseAddr(item)
+				if err != nil {
+					return fmt.Errorf("%q is not an IP address", item)
+				}
+
+				return nil
+			})
+		}
+	}
+}
+
+func (o *validator) validatePeer(section *Section, peersByKey map[string]int) {
+	publicKey, ok := section.Get("PublicKey")
+	if !ok {
+		o.add(ErrorSeverity, section.Line(), 1, "[%s] is missing PublicKey", section.Name)
+	} else if firstLine, isDuplicate := peersByKey[publicKey]; isDuplicate {
+		o.add(ErrorSeverity, section.Line(), 1,
+			"duplicate peer - the [%s] on line %d has the same PublicKey", section.Name, firstLine)
+	} else if publicKey != "" {
+		peersByKey[publicKey] = section.Line()
+	}
+
+	for _, line := range section.Entries() {
+		switch strings.ToLower(line.Key) {
+		case "publickey", "presharedkey":
+			o.validateKey(line)
+		case "endpoint":
+			err := validateEndpoint(line.Value)
+			if err != nil {
+				o.add(ErrorSeverity, line.Num, line.ValueColumn, "invalid Endpoint - %s", err)
+			}
+		case "allowedips":
+			o.validateList(line, validatePrefixOrAddr)
+		case "persistentkeepalive":
+			if !strings.EqualFold(line.Value, "off") {
+				o.validateInt(line)
+			}
+		}
+	}
+}
+
+func (o *validator) validateKey(line *Line) {
+	_, err := ParseKey(line.Value)
+	if err != nil {
+		o.add(ErrorSeverity, line.Num, line.ValueColumn, "invalid %s - %s", line.Key, err)
+	}
+}
+
+func (o *validator) validateInt(line *Line) {
+	_, err := decodeInt(line, 0, 65535)
+	if err != nil {
+		o.diagnostics = append(o.diagnostics, diagnosticFromErr(err))
+	}
+}
+
+// validateList validates each item of a comma-separated value,
+// reporting problems at the item's column.
+func (o *validator) validateList(line *Line, fn func(item string) error) {
+	offset := 0
+
+	for _, item := range strings.Split(line.Value, ",") {
+		trimmed := strings.TrimSpace(item)
+		column := line.ValueColumn + offset + strings.Index(item, trimmed)
+		offset += len(item) + 1
+
+		if trimmed == "" {
+			o.add(ErrorSeverity, line.Num, column, "%s has an empty item", line.Key)
+			continue
+		}
+
+		err := fn(trimmed)
+		if err != nil {
+			o.add(ErrorSeverity, line.Num, column, "invalid %s - %s", line.Key, err)
+			continue
+		}
+
+		if prefix, err := netip.ParsePrefix(trimmed); err == nil && prefix.Masked() != prefix &&
+			strings.EqualFold(line.Key, "AllowedIPs") {
+			o.add(WarningSeverity, line.Num, column,
+				"%s has host bits set - it is the same as %s", trimmed, prefix.Masked())
+		}
+	}
+}
+
+// validatePrefixOrAddr checks that s is a CIDR or an IP address.
+func validatePrefixOrAddr(s string) error {
+	_, err := netip.ParsePrefix(s)
+	if err == nil {
+		return nil
+	}
+
+	_, err = netip.ParseAddr(s)
+	if err == nil {
+		return nil
+	}
+
+	return fmt.Errorf("%q is not a CIDR or IP address", s)
+}
+
+// validateEndpoint checks that s is a host and port.
+func validateEndpoint(s string) error {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return fmt.Errorf("expected host:port - got %q", s)
+	}
+
+	if host == "" {
+		return fmt.Errorf("host is empty")
+	}
+
+	portNum, err := strconv.Atoi(port)
+	if err != nil || portNum < 1 || portNum > 65535 {
+		return fmt.Errorf("port must be a number from 1 to 65535 - got %q", port)
+	}
+
+	return nil
+}
+
+func keyColumn(line *Line) int {
+	return strings.Index(line.raw, line.Key) + 1
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,152 @@
+package wgconf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate_Valid(t *testing.T) {
+	diagnostics := Validate([]byte(exampleConfig))
+	if len(diagnostics) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", diagnostics)
+	}
+}
+
+func TestValidate_OtherSectionsAreNotChecked(t *testing.T) {
+	config := "[Interface]\nPrivateKey = cHJpdmF0ZWtleXByaXZhdGVrZXlwcml2YXRla2V5MDA=\n\n[Foo]\nBar = baz\n\n[Foo]\n"
+
+	diagnostics := Validate([]byte(config))
+	if len(diagnostics) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", diagnostics)
+	}
+}
+
+func TestValidate_Problems(t *testing.T) {
+	const validKey = "cHVibGlja2V5cHVibGlja2V5cHVibGlja2V5cHViMDA="
+
+	tests := []struct {
+		name     string
+		config   string
+		severity Severity
+		line     int
+		column   int
+		contains string
+	}{
+		{
+			name:     "syntax error",
+			config:   "[Interface",
+			severity: ErrorSeverity,
+			line:     1,
+			column:   11,
+			contains: "missing ']'",
+		},
+		{
+			name:     "missing interface",
+			config:   "[Peer]\nPublicKey = " + validKey,
+			severity: ErrorSeverity,
+			line:     1,
+			column:   1,
+			contains: "missing [Interface]",
+		},
+		{
+			name:     "unknown key",
+			config:   "[Interface]\nPrivateKey = " + validKey + "\n  PostUp = echo hi",
+			severity: WarningSeverity,
+			line:     3,
+			column:   3,
+			contains: `unknown key "PostUp"`,
+		},
+		{
+			name:     "malformed base64",
+			config:   "[Interface]\nPrivateKey = not-base64!",
+			severity: ErrorSeverity,
+			line:     2,
+			column:   14,
+			contains: "not valid base64",
+		},
+		{
+			name:     "short key",
+			config:   "[Interface]\nPrivateKey = YWJj",
+			severity: ErrorSeverity,
+			line:     2,
+			column:   14,
+			contains: "must be 32 bytes",
+		},
+		{
+			name:     "bad cidr",
+			config:   "[Interface]\nPrivateKey = " + validKey + "\n[Peer]\nPublicKey = " + validKey + "\nAllowedIPs = 10.0.0.0/24, 10.0.0.300/32",
+			severity: ErrorSeverity,
+			line:     5,
+			column:   27,
+			contains: "not a CIDR",
+		},
+		{
+			name:     "bad address",
+			config:   "[Interface]\nPrivateKey = " + validKey + "\nAddress = 10.0.0.1, fe80::1/64,10.0.0/8",
+			severity: ErrorSeverity,
+			line:     3,
+			column:   32,
+			contains: "not a CIDR",
+		},
+		{
+			name:     "host bits set",
+			config:   "[Interface]\nPrivateKey = " + validKey + "\n[Peer]\nPublicKey = " + validKey + "\nAllowedIPs = 10.0.0.1/24",
+			severity: WarningSeverity,
+			line:     5,
+			column:   14,
+			contains: "host bits",
+		},
+		{
+			name:     "invalid endpoint",
+			config:   "[Interface]\nPrivateKey = " + validKey + "\n[Peer]\nPublicKey = " + validKey + "\nEndpoint = example.com",
+			severity: ErrorSeverity,
+			line:     5,
+			column:   12,
+			contains: "expected host:port",
+		},
+		{
+			name:     "invalid endpoint port",
+			config:   "[Interface]\nPrivateKey = " + validKey + "\n[Peer]\nPublicKey = " + validKey + "\nEndpoint = example.com:0",
+			severity: ErrorSeverity,
+			line:     5,
+			column:   12,
+			contains: "port must be",
+		},
+		{
+			name:     "duplicate peer",
+			config:   "[Interface]\nPrivateKey = " + validKey + "\n[Peer]\nPublicKey = " + validKey + "\n[Peer]\nPublicKey = " + validKey,
+			severity: ErrorSeverity,
+			line:     5,
+			column:   1,
+			contains: "the [Peer] on line 3",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diagnostics := Validate([]byte(test.config))
+
+			for _, diagnostic := range diagnostics {
+				if diagnostic.Severity == test.severity &&
+					diagnostic.Line == test.line &&
+					diagnostic.Column == test.column &&
+					strings.Contains(diagnostic.Msg, test.contains) {
+					return
+				}
+			}
+
+			t.Fatalf("expected %s at line %d, column %d containing %q - got: %v",
+				test.severity, test.line, test.column, test.contains, diagnostics)
+		})
+	}
+}
+
+func TestHasErrors(t *testing.T) {
+	if HasErrors([]Diagnostic{{Severity: WarningSeverity}}) {
+		t.Fatal("warnings are not errors")
+	}
+
+	if !HasErrors([]Diagnostic{{Severity: WarningSeverity}, {Severity: ErrorSeverity}}) {
+		t.Fatal("expected an error")
+	}
+}
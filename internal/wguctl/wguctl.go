@@ -6,21 +6,58 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
+	"runtime"
+	"strings"
 	"sync"
 	"time"
 )
 
+// maxStderrTailLines is the number of trailing stderr lines a Wgu keeps
+// around so they can be included in exit errors.
+const maxStderrTailLines = 5
+
+// defaultStopGracePeriod is how long wgu is given to exit on its own
+// before it is killed.
+const defaultStopGracePeriod = 3 * time.Second
+
+// defaultReadyTimeout is how long wgu is given to become ready
+// when Config.OptReadyTimeout is not set.
+const defaultReadyTimeout = 3 * time.Second
+
 type Wgu struct {
-	once    sync.Once
-	process *exec.Cmd
-	stdin   io.WriteCloser
+	once        sync.Once
+	interrupted bool
+	process     *exec.Cmd
+	stdin       io.WriteCloser
+	gracePeriod time.Duration
+	exited      chan struct{}
+	exitErr     error
+
+	stderrTailMu sync.Mutex
+	stderrTail   []string
 }
 
 type Config struct {
 	ExePath    string
 	ConfigPath string
 	OptStderr  chan<- string
+
+	// OptStopGracePeriod is how long wgu is given to exit after
+	// being asked to stop before it is killed.
+	OptStopGracePeriod time.Duration
+
+	// OptReadyTimeout is how long wgu is given to report that it
+	// is ready before it is killed.
+	OptReadyTimeout time.Duration
+
+	// OptStatus, if set, receives the status wgu reports on stdout.
+	OptStatus chan<- Status
+
+	// OptRunner creates the wgu commands. ExecRunner is used
+	// if it is not set.
+	OptRunner Runner
 }
 
 func (o *Config) GetExePath() string {
@@ -31,14 +68,48 @@ func (o *Config) GetExePath() string {
 	return o.ExePath
 }
 
+func (o *Config) getRunner() Runner {
+	if o.OptRunner == nil {
+		return ExecRunner{}
+	}
+
+	return o.OptRunner
+}
+
+func (o *Config) getStopGracePeriod() time.Duration {
+	if o.OptStopGracePeriod <= 0 {
+		return defaultStopGracePeriod
+	}
+
+	return o.OptStopGracePeriod
+}
+
+func (o *Config) getReadyTimeout() time.Duration {
+	if o.OptReadyTimeout <= 0 {
+		return defaultReadyTimeout
+	}
+
+	return o.OptReadyTimeout
+}
+
 func StartWgu(ctx context.Context, config Config) (*Wgu, error) {
-	wgu := exec.CommandContext(ctx, config.ExePath, "up", "-c", config.ConfigPath) // TODO should this be config.GetExePath()?
+	wgu := config.getRunner().CommandContext(ctx, config.GetExePath(), "up", "-c", config.ConfigPath)
 
 	stdin, err := wgu.StdinPipe()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create stdin pipe - %w", err)
 	}
 
+	gracePeriod := config.getStopGracePeriod()
+
+	// Ask wgu to exit on its own when ctx is cancelled,
+	// and only kill it if it is still running after
+	// the grace period.
+	wgu.Cancel = func() error {
+		return interruptWgu(wgu, stdin)
+	}
+	wgu.WaitDelay = gracePeriod
+
 	stdout, err := wgu.StdoutPipe()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create stdout pipe - %w", err)
@@ -57,28 +128,47 @@ func StartWgu(ctx context.Context, config Config) (*Wgu, error) {
 		return nil, fmt.Errorf("failed to start wgu - %w", err)
 	}
 
-	// TODO we need to store this channel in the struct
-	exited := make(chan error, 1)
+	w := &Wgu{
+		process:     wgu,
+		stdin:       stdin,
+		gracePeriod: gracePeriod,
+		exited:      make(chan struct{}),
+	}
 
-	go func() {
-		exited <- wgu.Wait()
-	}()
+	stderrDone := make(chan struct{})
 
 	if stderr != nil {
 		go func() {
+			defer close(stderrDone)
+
 			stderrScanner := bufio.NewScanner(stderr)
 
 			for stderrScanner.Scan() {
+				line := stderrScanner.Text()
+
+				w.addStderrTail(line)
+
 				select {
 				case <-ctx.Done():
 					return
-				case config.OptStderr <- stderrScanner.Text():
+				case config.OptStderr <- line:
 					// keep going
 				}
 			}
 		}()
+	} else {
+		close(stderrDone)
 	}
 
+	go func() {
+		// Wait closes the pipes, so let the stderr reader
+		// finish first to avoid losing the last lines.
+		<-stderrDone
+
+		w.exitErr = wgu.Wait()
+		close(w.exited)
+	}()
+
 	isReady := make(chan error, 1)
 
 	go func() {
@@ -89,21 +179,40 @@ func StartWgu(ctx context.Context, config Config) (*Wgu, error) {
 			return
 		}
 
-		if scanner.Text() != "ready" {
+		msg, ok := parseStdoutLine(scanner.Text())
+		if !ok || msg.Type != readyStdoutMessageType {
 			isReady <- fmt.Errorf("failed to read 'ready' string from stdout - got: %q", scanner.Text())
 			return
 		}
 
 		isReady <- nil
+
+		if msg.hasStatus() {
+			w.sendStatus(ctx, config.OptStatus, msg.Status)
+		}
+
+		// Keep draining stdout so that wgu never blocks
+		// writing to it, and pass on status updates
+		for scanner.Scan() {
+			msg, ok := parseStdoutLine(scanner.Text())
+			if ok && msg.Type == statusStdoutMessageType {
+				w.sendStatus(ctx, config.OptStatus, msg.Status)
+			}
+		}
 	}()
 
-	timeout := time.After(3 * time.Second)
+	readyTimeout := config.getReadyTimeout()
+	timeout := time.After(readyTimeout)
 
 	select {
+	case <-ctx.Done():
+		_ = wgu.Process.Kill()
+		return nil, fmt.Errorf("cancelled while waiting for wgu to become ready - %w", ctx.Err())
 	case <-timeout:
 		_ = wgu.Process.Kill()
-		return nil, errors.New("timed out waiting for wgu to become ready")
-	case err := <-exited:
+		return nil, fmt.Errorf("timed out after %s waiting for wgu to become ready", readyTimeout)
+	case <-w.exited:
+		err := w.ExitErr()
 		if err != nil {
 			return nil, fmt.Errorf("wgu process exited unexpectedly while waiting for 'ready' - %w", err)
 		}
@@ -115,11 +224,142 @@ func StartWgu(ctx context.Context, config Config) (*Wgu, error) {
 			return nil, fmt.Errorf("failed to get 'ready' result - %w", err)
 		}
 
-		return &Wgu{process: wgu, stdin: stdin}, nil
+		return w, nil
+	}
+}
+
+func (o *Wgu) sendStatus(ctx context.Context, statusCh chan<- Status, status Status) {
+	if statusCh == nil {
+		return
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-o.exited:
+	case statusCh <- status:
+	}
+}
+
+// Exited returns a channel that is closed when the wgu process exits.
+func (o *Wgu) Exited() <-chan struct{} {
+	return o.exited
+}
+
+// ExitErr returns the error returned by waiting on the wgu process.
+// It is only meaningful after the Exited channel has been closed.
+func (o *Wgu) ExitErr() error {
+	select {
+	case <-o.exited:
+		return o.exitErr
+	default:
+		return nil
+	}
+}
+
+// ExitCode returns the exit code of the wgu process, or -1 if the
+// process has not exited or was terminated by a signal.
+func (o *Wgu) ExitCode() int {
+	select {
+	case <-o.exited:
+		return o.process.ProcessState.ExitCode()
+	default:
+		return -1
+	}
+}
+
+// StderrTail returns the last few lines wgu wrote to stderr.
+func (o *Wgu) StderrTail() []string {
+	o.stderrTailMu.Lock()
+	defer o.stderrTailMu.Unlock()
+
+	tail := make([]string, len(o.stderrTail))
+	copy(tail, o.stderrTail)
+
+	return tail
+}
+
+func (o *Wgu) addStderrTail(line string) {
+	o.stderrTailMu.Lock()
+	defer o.stderrTailMu.Unlock()
+
+	o.stderrTail = append(o.stderrTail, line)
+	if len(o.stderrTail) > maxStderrTailLines {
+		o.stderrTail = o.stderrTail[len(o.stderrTail)-maxStderrTailLines:]
+	}
+}
+
+// unexpectedExitErr describes why the wgu process exited, including
+// its exit code and the last lines it wrote to stderr.
+func (o *Wgu) unexpectedExitErr() error {
+	var tail string
+	if lines := o.StderrTail(); len(lines) > 0 {
+		tail = " - last stderr: '" + strings.Join(lines, "\n") + "'"
 	}
+
+	err := o.ExitErr()
+	if err != nil {
+		return fmt.Errorf("wgu process exited unexpectedly with code %d - %w%s",
+			o.ExitCode(), err, tail)
+	}
+
+	return fmt.Errorf("wgu process exited unexpectedly with code %d%s",
+		o.ExitCode(), tail)
 }
 
+// Stop asks wgu to exit by closing its stdin and sending it an
+// interrupt. If wgu has not exited once the grace period elapses,
+// it is killed and an error is returned.
+//
+// wgu exiting with a non-zero code or being terminated by the
+// interrupt is treated as a clean stop. If wgu had already exited
+// on its own before being asked to stop, the error it exited
+// with is returned.
 func (o *Wgu) Stop() error {
-	o.stdin.Close()
-	return o.process.Process.Kill()
+	o.once.Do(func() {
+		select {
+		case <-o.exited:
+			return
+		default:
+		}
+
+		o.interrupted = true
+		_ = interruptWgu(o.process, o.stdin)
+	})
+
+	timer := time.NewTimer(o.gracePeriod)
+	defer timer.Stop()
+
+	select {
+	case <-o.exited:
+		if o.interrupted {
+			return nil
+		}
+
+		return o.exitErr
+	case <-timer.C:
+	}
+
+	err := o.process.Process.Kill()
+	if err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("failed to kill wgu after %s grace period - %w", o.gracePeriod, err)
+	}
+
+	<-o.exited
+
+	return fmt.Errorf("wgu did not exit within %s grace period and was killed - %w",
+		o.gracePeriod, o.exitErr)
+}
+
+// interruptWgu closes wgu's stdin and sends it an interrupt.
+// Interrupts are not supported on Windows, where closing stdin
+// is the only way to ask wgu to exit.
+func interruptWgu(wgu *exec.Cmd, stdin io.Closer) error {
+	_ = stdin.Close()
+
+	err := wgu.Process.Signal(os.Interrupt)
+	if err != nil && !errors.Is(err, os.ErrProcessDone) && runtime.GOOS != "windows" {
+		return err
+	}
+
+	return nil
 }
@@ -0,0 +1,352 @@
+package wguctl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeWguPath is the path to the fake wgu built from testdata/fakewgu
+// by TestMain.
+var fakeWguPath string
+
+func TestMain(m *testing.M) {
+	os.Exit(testMainWithExitCode(m))
+}
+
+func testMainWithExitCode(m *testing.M) int {
+	tempDir, err := os.MkdirTemp("", "wguctl-test-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to create temp dir -", err)
+		return 1
+	}
+	defer os.RemoveAll(tempDir)
+
+	fakeWguPath = filepath.Join(tempDir, "fakewgu")
+	if runtime.GOOS == "windows" {
+		fakeWguPath += ".exe"
+	}
+
+	build := exec.Command("go", "build", "-o", fakeWguPath, "./testdata/fakewgu")
+	output, err := build.CombinedOutput()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to build fake wgu - %s - output: '%s'\n", err, output)
+		return 1
+	}
+
+	return m.Run()
+}
+
+// fakeRunner is a Runner that runs the fake wgu in place of wgu.
+type fakeRunner struct {
+	mode  string
+	delay time.Duration
+	lines int
+}
+
+func (o fakeRunner) CommandContext(ctx context.Context, _ string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, fakeWguPath, args...)
+
+	cmd.Env = append(os.Environ(), "FAKEWGU_MODE="+o.mode)
+	if o.delay > 0 {
+		cmd.Env = append(cmd.Env, "FAKEWGU_DELAY="+o.delay.String())
+	}
+	if o.lines > 0 {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("FAKEWGU_LINES=%d", o.lines))
+	}
+
+	return cmd
+}
+
+func fakeConfig(t *testing.T, runner fakeRunner) Config {
+	t.Helper()
+
+	configPath := filepath.Join(t.TempDir(), "test.conf")
+
+	err := os.WriteFile(configPath, []byte("[Interface]\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return Config{
+		ExePath:            "wgu",
+		ConfigPath:         configPath,
+		OptStopGracePeriod: time.Second,
+		OptReadyTimeout:    5 * time.Second,
+		OptRunner:          runner,
+	}
+}
+
+func startFakeWgu(t *testing.T, config Config) *Wgu {
+	t.Helper()
+
+	wgu, err := StartWgu(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = wgu.Stop()
+	})
+
+	return wgu
+}
+
+func TestStartWgu_Ready(t *testing.T) {
+	wgu := startFakeWgu(t, fakeConfig(t, fakeRunner{mode: "ready"}))
+
+	select {
+	case <-wgu.Exited():
+		t.Fatal("wgu exited after becoming ready")
+	default:
+	}
+
+	err := wgu.Stop()
+	if err != nil {
+		t.Fatalf("failed to stop wgu - %v", err)
+	}
+
+	if code := wgu.ExitCode(); code != 0 {
+		t.Fatalf("exit code: got %d, want 0", code)
+	}
+}
+
+func TestStartWgu_Slow(t *testing.T) {
+	config := fakeConfig(t, fakeRunner{mode: "slow", delay: 300 * time.Millisecond})
+
+	start := time.Now()
+
+	startFakeWgu(t, config)
+
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Fatalf("StartWgu returned after %s, before wgu was ready", elapsed)
+	}
+}
+
+func TestStartWgu_ReadyTimeout(t *testing.T) {
+	config := fakeConfig(t, fakeRunner{mode: "slow", delay: 10 * time.Second})
+	config.OptReadyTimeout = 200 * time.Millisecond
+
+	start := time.Now()
+
+	_, err := StartWgu(context.Background(), config)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if !strings.Contains(err.Error(), "timed out after 200ms") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("StartWgu took %s to time out", elapsed)
+	}
+}
+
+func TestStartWgu_Cancelled(t *testing.T) {
+	config := fakeConfig(t, fakeRunner{mode: "slow", delay: 10 * time.Second})
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancelFn()
+
+	_, err := StartWgu(ctx, config)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a context error - got: %v", err)
+	}
+}
+
+func TestStartWgu_ExitBeforeReady(t *testing.T) {
+	config := fakeConfig(t, fakeRunner{mode: "exit-before-ready"})
+
+	_, err := StartWgu(context.Background(), config)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if code := exitErr.ExitCode(); code != 2 {
+			t.Fatalf("exit code: got %d, want 2", code)
+		}
+	} else if !strings.Contains(err.Error(), "ready") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestStartWgu_Garbage(t *testing.T) {
+	config := fakeConfig(t, fakeRunner{mode: "garbage"})
+
+	_, err := StartWgu(context.Background(), config)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if !strings.Contains(err.Error(), "failed to read 'ready'") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestStartWgu_CrashAfterReady(t *testing.T) {
+	stderr := make(chan string, 10)
+
+	config := fakeConfig(t, fakeRunner{mode: "crash-after-ready"})
+	config.OptStderr = stderr
+
+	wgu := startFakeWgu(t, config)
+
+	select {
+	case <-wgu.Exited():
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for wgu to exit")
+	}
+
+	if code := wgu.ExitCode(); code != 1 {
+		t.Fatalf("exit code: got %d, want 1", code)
+	}
+
+	err := wgu.unexpectedExitErr()
+	if !strings.Contains(err.Error(), "something went wrong") {
+		t.Fatalf("exit error does not include stderr tail: %v", err)
+	}
+}
+
+func TestStartWgu_StderrFlood(t *testing.T) {
+	const numLines = 5000
+
+	stderr := make(chan string)
+
+	config := fakeConfig(t, fakeRunner{mode: "stderr-flood", lines: numLines})
+	config.OptStderr = stderr
+
+	startFakeWgu(t, config)
+
+	timeout := time.After(10 * time.Second)
+
+	for i := 0; i < numLines; i++ {
+		select {
+		case line := <-stderr:
+			if want := fmt.Sprintf("flood line %d", i); !strings.HasSuffix(line, want) {
+				t.Fatalf("line %d: got %q, want suffix %q", i, line, want)
+			}
+		case <-timeout:
+			t.Fatalf("timed out after reading %d lines", i)
+		}
+	}
+}
+
+func TestStartWgu_Status(t *testing.T) {
+	statuses := make(chan Status, 2)
+
+	config := fakeConfig(t, fakeRunner{mode: "status"})
+	config.OptStatus = statuses
+
+	startFakeWgu(t, config)
+
+	for _, wantPeers := range []int{1, 2} {
+		select {
+		case status := <-statuses:
+			if status.PeerCount != wantPeers {
+				t.Fatalf("peer count: got %d, want %d", status.PeerCount, wantPeers)
+			}
+
+			if status.ListenAddr != "127.0.0.1:51820" {
+				t.Fatalf("listen addr: got %q", status.ListenAddr)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for status with %d peers", wantPeers)
+		}
+	}
+}
+
+func TestWgu_StopKillsStubbornWgu(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupts are not supported on windows")
+	}
+
+	config := fakeConfig(t, fakeRunner{mode: "stubborn"})
+	config.OptStopGracePeriod = 200 * time.Millisecond
+
+	wgu := startFakeWgu(t, config)
+
+	err := wgu.Stop()
+	if err == nil || !strings.Contains(err.Error(), "was killed") {
+		t.Fatalf("expected a killed error - got: %v", err)
+	}
+}
+
+func TestWgu_StopWguKilledByInterrupt(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupts are not supported on windows")
+	}
+
+	wgu := startFakeWgu(t, fakeConfig(t, fakeRunner{mode: "die-on-interrupt"}))
+
+	err := wgu.Stop()
+	if err != nil {
+		t.Fatalf("wgu exiting due to the interrupt should not be an error - got: %v", err)
+	}
+
+	if wgu.ExitErr() == nil {
+		t.Fatal("expected wgu to have been terminated by the interrupt")
+	}
+}
+
+func TestGetPublicKeyFromConfig(t *testing.T) {
+	config := fakeConfig(t, fakeRunner{})
+
+	pubkey, err := GetPublicKeyFromConfig(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pubkey != "RmFrZVB1YmxpY0tleUZha2VQdWJsaWNLZXlGYWtlUHU=" {
+		t.Fatalf("unexpected public key: %q", pubkey)
+	}
+}
+
+func TestGetPublicKeyFromConfig_MissingConfig(t *testing.T) {
+	config := fakeConfig(t, fakeRunner{})
+	config.ConfigPath = filepath.Join(t.TempDir(), "missing.conf")
+
+	_, err := GetPublicKeyFromConfig(context.Background(), config)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if !strings.Contains(err.Error(), "stderr:") {
+		t.Fatalf("error does not include stderr: %v", err)
+	}
+}
+
+func TestCreateConfig(t *testing.T) {
+	config := fakeConfig(t, fakeRunner{})
+	config.ConfigPath = t.TempDir()
+
+	err := CreateConfig(context.Background(), config, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = os.Stat(filepath.Join(config.ConfigPath, "default.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetVersion(t *testing.T) {
+	version, err := GetVersion(context.Background(), fakeConfig(t, fakeRunner{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if version != "v0.0.0-fake" {
+		t.Fatalf("unexpected version: %q", version)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/SeungKang/wgui/internal/rotlog"
+	"github.com/SeungKang/wgui/internal/wguctl"
+)
+
+const (
+	// maxLogFileSize is the size a log file can grow to before it
+	// is rotated.
+	maxLogFileSize = 1 << 20
+
+	// maxLogFiles is the number of rotated log files kept per log.
+	maxLogFiles = 5
+
+	// wguiLogName is the log file name for wgui's own errLogger.
+	wguiLogName = "wgui"
+)
+
+// logSession is the part of a profile's log files written during
+// one connection attempt.
+type logSession struct {
+	started string
+	lines   []string
+}
+
+// logFilePath returns the path of the current log file for name.
+func (s *State) logFilePath(name string) string {
+	return filepath.Join(s.logDir, name+".log")
+}
+
+// openProfileLogFile opens the rotating log file for a profile,
+// returning nil if persisting logs is disabled.
+func (s *State) openProfileLogFile(profileName string) *rotlog.Writer {
+	if s.logDir == "" {
+		return nil
+	}
+
+	w, err := rotlog.Open(s.logFilePath(profileName), maxLogFileSize, maxLogFiles)
+	if err != nil {
+		s.errLogger.Printf("failed to open log file for profile %q - %v", profileName, err)
+		return nil
+	}
+
+	return w
+}
+
+// readLogSessions reads a log and its rotated files and splits them
+// into sessions, newest first.
+func readLogSessions(logPath string) ([]logSession, error) {
+	var sessions []logSession
+
+	for _, path := range rotlog.Files(logPath, maxLogFiles) {
+		data, err := rotlog.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read log file %s - %w", path, err)
+		}
+
+		for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+			if line == "" {
+				continue
+			}
+
+			if strings.HasSuffix(line, wguctl.LogFileSessionMarker) || len(sessions) == 0 {
+				started := strings.TrimSpace(strings.TrimSuffix(line, wguctl.LogFileSessionMarker))
+				if len(started) > len(wguctl.LogFileTimeFormat) {
+					started = started[:len(wguctl.LogFileTimeFormat)]
+				}
+
+				sessions = append(sessions, logSession{started: started})
+
+				if strings.HasSuffix(line, wguctl.LogFileSessionMarker) {
+					continue
+				}
+			}
+
+			last := &sessions[len(sessions)-1]
+			last.lines = append(last.lines, line)
+		}
+	}
+
+	for i, j := 0, len(sessions)-1; i < j; i, j = i+1, j-1 {
+		sessions[i], sessions[j] = sessions[j], sessions[i]
+	}
+
+	return sessions, nil
+}
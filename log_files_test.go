@@ -0,0 +1,87 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/SeungKang/wgui/internal/rotlog"
+	"github.com/SeungKang/wgui/internal/wguctl"
+)
+
+func TestReadLogSessions(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.log")
+
+	// Small enough that the first session ends up in a rotated file
+	w, err := rotlog.Open(logPath, 150, maxLogFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	lines := []string{
+		"2024/01/02 03:04:05.000 left over from an older session",
+		"2024/01/02 03:04:06.000 " + wguctl.LogFileSessionMarker,
+		"2024/01/02 03:04:07.000 first session",
+		"2024/01/02 03:05:00.000 " + wguctl.LogFileSessionMarker,
+		"2024/01/02 03:05:01.000 second session",
+		"2024/01/02 03:05:02.000 still second session",
+	}
+
+	for _, line := range lines {
+		_, err = w.Write([]byte(line + "\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if files := rotlog.Files(logPath, maxLogFiles); len(files) < 2 {
+		t.Fatalf("expected the log to be rotated, got %v", files)
+	}
+
+	sessions, err := readLogSessions(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []logSession{
+		{
+			started: "2024/01/02 03:05:00.000",
+			lines:   lines[4:6],
+		},
+		{
+			started: "2024/01/02 03:04:06.000",
+			lines:   lines[2:3],
+		},
+		{
+			started: "2024/01/02 03:04:05.000",
+			lines:   lines[0:1],
+		},
+	}
+
+	if len(sessions) != len(want) {
+		t.Fatalf("got %d sessions, want %d: %+v", len(sessions), len(want), sessions)
+	}
+
+	for i := range want {
+		if sessions[i].started != want[i].started {
+			t.Errorf("session %d: got start %q, want %q", i, sessions[i].started, want[i].started)
+		}
+
+		if len(sessions[i].lines) != len(want[i].lines) {
+			t.Fatalf("session %d: got lines %q, want %q", i, sessions[i].lines, want[i].lines)
+		}
+
+		for j := range want[i].lines {
+			if sessions[i].lines[j] != want[i].lines[j] {
+				t.Errorf("session %d line %d: got %q, want %q", i, j, sessions[i].lines[j], want[i].lines[j])
+			}
+		}
+	}
+}
+
+func TestReadLogSessions_NoFiles(t *testing.T) {
+	sessions, err := readLogSessions(filepath.Join(t.TempDir(), "missing.log"))
+	if err != nil || len(sessions) != 0 {
+		t.Fatalf("got %v, %v", sessions, err)
+	}
+}
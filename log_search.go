@@ -0,0 +1,77 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// logSearch matches log lines against a substring or a regular expression.
+type logSearch struct {
+	query   string
+	isRegex bool
+	re      *regexp.Regexp
+	err     error
+}
+
+func newLogSearch(query string, isRegex bool) logSearch {
+	search := logSearch{
+		query:   query,
+		isRegex: isRegex,
+	}
+
+	if isRegex && query != "" {
+		search.re, search.err = regexp.Compile(query)
+	}
+
+	return search
+}
+
+// isActive reports whether there is a valid query to match against.
+func (o logSearch) isActive() bool {
+	return o.query != "" && o.err == nil
+}
+
+// find returns the [start, end) byte offsets of every match in text.
+func (o logSearch) find(text string) [][]int {
+	if !o.isActive() {
+		return nil
+	}
+
+	if o.isRegex {
+		var spans [][]int
+		for _, span := range o.re.FindAllStringIndex(text, -1) {
+			// Skip empty matches, there is nothing to highlight
+			if span[0] != span[1] {
+				spans = append(spans, span)
+			}
+		}
+
+		return spans
+	}
+
+	var spans [][]int
+	for offset := 0; offset < len(text); {
+		i := strings.Index(text[offset:], o.query)
+		if i < 0 {
+			break
+		}
+
+		start := offset + i
+		spans = append(spans, []int{start, start + len(o.query)})
+		offset = start + len(o.query)
+	}
+
+	return spans
+}
+
+func (o logSearch) matches(text string) bool {
+	if !o.isActive() {
+		return false
+	}
+
+	if o.isRegex {
+		return o.re.MatchString(text)
+	}
+
+	return strings.Contains(text, o.query)
+}
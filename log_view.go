@@ -0,0 +1,471 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"unicode/utf8"
+
+	"github.com/SeungKang/wgui/internal/wguctl"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// logView is a line based view of a profile's wgu logs. It does not
+// keep the lines, but reads the visible ones from the Fsm's log buffer
+// by sequence number, so the view shows exactly what is buffered.
+type logView struct {
+	fsm         *wguctl.Fsm
+	list        *widget.List
+	selectables map[uint64]*widget.Selectable
+	jumpButton  *widget.Clickable
+
+	// first and last are the sequence numbers of the oldest and
+	// newest buffered lines as of the last update. first is greater
+	// than last if there are none.
+	first uint64
+	last  uint64
+
+	// search
+	searchEditor *widget.Editor
+	regexToggle  *widget.Bool
+	filterToggle *widget.Bool
+	prevButton   *widget.Clickable
+	nextButton   *widget.Clickable
+	search       logSearch
+	matches      []uint64
+	currentMatch int
+}
+
+func newLogView(fsm *wguctl.Fsm) *logView {
+	return &logView{
+		fsm:   fsm,
+		first: 1,
+		list: &widget.List{
+			List: layout.List{
+				Axis:        layout.Vertical,
+				ScrollToEnd: true,
+			},
+		},
+		selectables:  make(map[uint64]*widget.Selectable),
+		jumpButton:   new(widget.Clickable),
+		searchEditor: &widget.Editor{SingleLine: true, Submit: true},
+		regexToggle:  new(widget.Bool),
+		filterToggle: new(widget.Bool),
+		prevButton:   new(widget.Clickable),
+		nextButton:   new(widget.Clickable),
+		currentMatch: -1,
+	}
+}
+
+// update catches up with the lines the Fsm received and evicted
+// since the last update.
+func (o *logView) update() {
+	first, last := o.fsm.LogBounds()
+	if first == o.first && last == o.last {
+		return
+	}
+
+	if o.search.isActive() {
+		for _, line := range o.fsm.LogLines(o.last) {
+			// Lines received since LogBounds are left for the next update
+			if line.Seq > last {
+				break
+			}
+
+			if o.search.matches(line.Text) {
+				o.matches = append(o.matches, line.Seq)
+			}
+		}
+	}
+
+	// The number of rows that were shown and have been evicted
+	var dropped int
+	if o.last >= o.first && first > o.first {
+		dropped = int(min(first, o.last+1) - o.first)
+	}
+
+	o.first, o.last = first, last
+
+	if dropped > 0 {
+		o.dropMatchesBefore(first)
+
+		// Keep the view still if the user scrolled up
+		if !o.filterToggle.Value {
+			o.list.Position.First = max(0, o.list.Position.First-dropped)
+		}
+	}
+}
+
+// dropMatchesBefore forgets matches for lines older than seq,
+// which were evicted.
+func (o *logView) dropMatchesBefore(seq uint64) {
+	var kept []uint64
+	for _, match := range o.matches {
+		if match >= seq {
+			kept = append(kept, match)
+		}
+	}
+
+	removed := len(o.matches) - len(kept)
+	o.matches = kept
+
+	if o.currentMatch >= 0 {
+		o.currentMatch = max(-1, o.currentMatch-removed)
+	}
+}
+
+// setSearch replaces the search and finds every matching line.
+func (o *logView) setSearch(search logSearch) {
+	o.search = search
+	o.matches = nil
+	o.currentMatch = -1
+
+	if !search.isActive() {
+		return
+	}
+
+	for _, line := range o.fsm.LogLines(o.first - 1) {
+		if line.Seq > o.last {
+			break
+		}
+
+		if search.matches(line.Text) {
+			o.matches = append(o.matches, line.Seq)
+		}
+	}
+}
+
+// rowCount returns the number of rows the list shows, which is
+// only the matching lines when filtering.
+func (o *logView) rowCount() int {
+	if o.isFiltering() {
+		return len(o.matches)
+	}
+
+	if o.last < o.first {
+		return 0
+	}
+
+	return int(o.last - o.first + 1)
+}
+
+// rowLine maps a list row to the sequence number of its line.
+func (o *logView) rowLine(row int) uint64 {
+	if o.isFiltering() {
+		return o.matches[row]
+	}
+
+	return o.first + uint64(row)
+}
+
+func (o *logView) isFiltering() bool {
+	return o.filterToggle.Value && o.search.isActive()
+}
+
+// moveMatch selects the next (delta 1) or previous (delta -1) match
+// and scrolls to it.
+func (o *logView) moveMatch(delta int) {
+	if len(o.matches) == 0 {
+		return
+	}
+
+	o.currentMatch += delta
+	if o.currentMatch < 0 {
+		o.currentMatch = len(o.matches) - 1
+	} else if o.currentMatch >= len(o.matches) {
+		o.currentMatch = 0
+	}
+
+	o.scrollToCurrentMatch()
+}
+
+func (o *logView) scrollToCurrentMatch() {
+	if o.currentMatch < 0 || o.currentMatch >= len(o.matches) {
+		return
+	}
+
+	row := int(o.matches[o.currentMatch] - o.first)
+	if o.isFiltering() {
+		row = o.currentMatch
+	}
+
+	o.list.Position = layout.Position{BeforeEnd: true, First: row}
+}
+
+// isCurrentMatch reports whether the line with the given sequence
+// number is the selected match.
+func (o *logView) isCurrentMatch(seq uint64) bool {
+	return o.currentMatch >= 0 && o.currentMatch < len(o.matches) && o.matches[o.currentMatch] == seq
+}
+
+// isFollowing reports whether the view is stuck to the latest line.
+func (o *logView) isFollowing() bool {
+	return !o.list.Position.BeforeEnd
+}
+
+// jumpToLatest scrolls to the latest line and resumes following.
+func (o *logView) jumpToLatest() {
+	o.list.Position.BeforeEnd = false
+}
+
+// selectable returns the selection state for a line, creating it
+// if needed. Only lines that are visible keep their state.
+func (o *logView) selectable(seq uint64) *widget.Selectable {
+	sel, ok := o.selectables[seq]
+	if !ok {
+		sel = new(widget.Selectable)
+		o.selectables[seq] = sel
+	}
+
+	return sel
+}
+
+// pruneSelectables forgets the selection state of lines that
+// were not visible in the last layout.
+func (o *logView) pruneSelectables() {
+	first := o.list.Position.First
+	last := min(first+o.list.Position.Count, o.rowCount()) - 1
+	if first >= o.rowCount() || last < first {
+		clear(o.selectables)
+		return
+	}
+
+	minSeq, maxSeq := o.rowLine(first), o.rowLine(last)
+
+	for seq := range o.selectables {
+		if seq < minSeq || seq > maxSeq {
+			delete(o.selectables, seq)
+		}
+	}
+}
+
+// handleSearchUpdates applies changes to the search box and toggles,
+// and handles the next/previous match buttons
+func (s *State) handleSearchUpdates(gtx layout.Context, logs *logView) {
+	for {
+		e, ok := logs.searchEditor.Update(gtx)
+		if !ok {
+			break
+		}
+
+		if _, isSubmit := e.(widget.SubmitEvent); isSubmit {
+			logs.moveMatch(1)
+		}
+	}
+
+	if logs.filterToggle.Update(gtx) {
+		logs.scrollToCurrentMatch()
+		if logs.currentMatch < 0 {
+			logs.jumpToLatest()
+		}
+	}
+
+	regexChanged := logs.regexToggle.Update(gtx)
+	query := logs.searchEditor.Text()
+	if regexChanged || query != logs.search.query {
+		logs.setSearch(newLogSearch(query, logs.regexToggle.Value))
+	}
+
+	for logs.prevButton.Clicked(gtx) {
+		logs.moveMatch(-1)
+	}
+
+	for logs.nextButton.Clicked(gtx) {
+		logs.moveMatch(1)
+	}
+}
+
+// renderLogSearchBar shows the search box, search options and
+// match navigation above the logs
+func (s *State) renderLogSearchBar(gtx layout.Context, logs *logView) layout.Dimensions {
+	s.handleSearchUpdates(gtx, logs)
+
+	return layout.Inset{Left: unit.Dp(16), Right: unit.Dp(16), Bottom: unit.Dp(8)}.Layout(gtx, func(gtx C) D {
+		return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+			layout.Flexed(1, func(gtx C) D {
+				return s.renderTextEditor(gtx, logs.searchEditor, "Search logs", unit.Dp(30))
+			}),
+			layout.Rigid(func(gtx C) D {
+				return s.renderSearchCheckBox(gtx, logs.regexToggle, "Regex")
+			}),
+			layout.Rigid(func(gtx C) D {
+				return s.renderSearchCheckBox(gtx, logs.filterToggle, "Only matching")
+			}),
+			layout.Rigid(func(gtx C) D {
+				return s.renderSearchStatus(gtx, logs)
+			}),
+			layout.Rigid(func(gtx C) D {
+				return layout.Inset{Left: unit.Dp(8)}.Layout(gtx, func(gtx C) D {
+					return s.renderSmallButton(gtx, logs.prevButton, "Prev")
+				})
+			}),
+			layout.Rigid(func(gtx C) D {
+				return layout.Inset{Left: unit.Dp(4)}.Layout(gtx, func(gtx C) D {
+					return s.renderSmallButton(gtx, logs.nextButton, "Next")
+				})
+			}),
+		)
+	})
+}
+
+// renderSearchCheckBox shows a search option check box
+func (s *State) renderSearchCheckBox(gtx layout.Context, value *widget.Bool, label string) layout.Dimensions {
+	return layout.Inset{Left: unit.Dp(8)}.Layout(gtx, func(gtx C) D {
+		box := material.CheckBox(s.theme, value, label)
+		box.Color = LightGreyColor
+		box.IconColor = PinkColor
+		box.TextSize = unit.Sp(12)
+		box.Size = unit.Dp(18)
+		return box.Layout(gtx)
+	})
+}
+
+// renderSearchStatus shows the selected match and match count,
+// or why the query is invalid
+func (s *State) renderSearchStatus(gtx layout.Context, logs *logView) layout.Dimensions {
+	var status string
+	switch {
+	case logs.search.err != nil:
+		status = "invalid regex"
+	case !logs.search.isActive():
+		return D{}
+	case logs.currentMatch < 0:
+		status = fmt.Sprintf("%d matches", len(logs.matches))
+	default:
+		status = fmt.Sprintf("%d/%d", logs.currentMatch+1, len(logs.matches))
+	}
+
+	label := material.Label(s.theme, 12, status)
+	label.Color = LightGreyColor
+	if logs.search.err != nil {
+		label.Color = RedColor
+	}
+
+	return layout.Inset{Left: unit.Dp(8)}.Layout(gtx, label.Layout)
+}
+
+// renderSmallButton shows a compact button used in toolbars
+func (s *State) renderSmallButton(gtx layout.Context, button *widget.Clickable, label string) layout.Dimensions {
+	btn := material.Button(s.theme, button, label)
+	btn.Background = GreyColor
+	btn.TextSize = unit.Sp(12)
+	btn.Inset = layout.UniformInset(unit.Dp(6))
+	return btn.Layout(gtx)
+}
+
+// renderLogLine displays one log line with its timestamp in a gutter
+func (s *State) renderLogLine(gtx layout.Context, logs *logView, seq uint64) layout.Dimensions {
+	// The line may have been evicted since the last update
+	line, ok := logs.fsm.LogLine(seq)
+	if !ok {
+		return D{}
+	}
+
+	return layout.Inset{
+		Top: unit.Dp(1), Bottom: unit.Dp(1),
+		Left: unit.Dp(16), Right: unit.Dp(8),
+	}.Layout(gtx, func(gtx C) D {
+		return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+			layout.Rigid(func(gtx C) D {
+				timestamp := s.logText(line.Time.Format("15:04:05.000"))
+				timestamp.Color = GreyColor
+
+				return layout.Inset{Right: unit.Dp(12)}.Layout(gtx, timestamp.Layout)
+			}),
+			layout.Flexed(1, func(gtx C) D {
+				row := s.logText(line.Text)
+				row.Color = logLevelColor(line.Level)
+				row.State = logs.selectable(line.Seq)
+
+				spans := logs.search.find(line.Text)
+				if len(spans) == 0 {
+					return row.Layout(gtx)
+				}
+
+				return s.renderHighlightedLogText(gtx, row, spans, logs.isCurrentMatch(seq))
+			}),
+		)
+	})
+}
+
+// renderHighlightedLogText displays a log line with its search
+// matches highlighted behind the text, so that the line stays
+// selectable and wraps like lines without matches
+func (s *State) renderHighlightedLogText(gtx layout.Context, row material.LabelStyle, spans [][]int, isCurrent bool) layout.Dimensions {
+	highlight := HighlightColor
+	if isCurrent {
+		highlight = PinkColor
+	}
+	highlight.A = 0x80
+
+	// Lay the text out first so that the positions of the matches
+	// are known, and draw it over the highlights
+	macro := op.Record(gtx.Ops)
+	dims := row.Layout(gtx)
+	call := macro.Stop()
+
+	var regions []widget.Region
+	for _, span := range spans {
+		// Regions are measured in runes
+		start := utf8.RuneCountInString(row.Text[:span[0]])
+		end := start + utf8.RuneCountInString(row.Text[span[0]:span[1]])
+
+		regions = row.State.Regions(start, end, regions[:0])
+		for _, region := range regions {
+			paint.FillShape(gtx.Ops, highlight, clip.Rect(region.Bounds).Op())
+		}
+	}
+
+	call.Add(gtx.Ops)
+
+	return dims
+}
+
+// logLevelColor returns the text color for a log line's level
+func logLevelColor(level wguctl.LogLevel) color.NRGBA {
+	switch level {
+	case wguctl.ErrorLogLevel:
+		return LogErrorColor
+	case wguctl.WarnLogLevel:
+		return HighlightColor
+	case wguctl.DebugLogLevel:
+		return LightGreyColor
+	default:
+		return WhiteColor
+	}
+}
+
+// logText returns a label styled for the log view
+func (s *State) logText(str string) material.LabelStyle {
+	label := material.Body1(s.theme, str)
+	label.Color = WhiteColor
+	label.TextSize = unit.Sp(12)
+	label.Font.Typeface = "monospace"
+	return label
+}
+
+// renderJumpToLatestButton shows a button that resumes following
+// the logs after the user scrolled up
+func (s *State) renderJumpToLatestButton(gtx layout.Context, logs *logView) layout.Dimensions {
+	if logs.isFollowing() {
+		return D{}
+	}
+
+	return layout.UniformInset(unit.Dp(12)).Layout(gtx, func(gtx C) D {
+		for logs.jumpButton.Clicked(gtx) {
+			logs.jumpToLatest()
+		}
+
+		btn := material.Button(s.theme, logs.jumpButton, "Jump to latest")
+		btn.Background = PurpleColor
+		btn.TextSize = unit.Sp(12)
+		btn.Inset = layout.UniformInset(unit.Dp(6))
+		return btn.Layout(gtx)
+	})
+}
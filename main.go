@@ -17,6 +17,13 @@ import (
 var version = "dev"
 
 func main() {
+	persistLogs := flag.Bool("persist-logs", false,
+		"Save wgu and wgui logs to rotating files in ~/.wgu/logs")
+
+	readyTimeout := flag.Duration("ready-timeout", 3*time.Second,
+		"How long to wait for wgu to become ready. Profiles can override it with\n"+
+			"a '# wgui.ReadyTimeout = 10s' comment in their config")
+
 	flag.Parse()
 
 	ctx, cancelFn := signal.NotifyContext(context.Background(),
@@ -30,12 +37,15 @@ func main() {
 			app.Title(fmt.Sprintf("wgui [%s]", version)),
 		)
 
-		s := NewState(ctx, w)
+		s := NewState(ctx, w, stateConfig{
+			persistLogs:  *persistLogs,
+			readyTimeout: *readyTimeout,
+		})
 
 		err := s.Run(ctx, w)
 		cancelFn()
 
-		timeoutctx, cancelTimeoutFn := context.WithTimeout(ctx, 2*time.Second)
+		timeoutctx, cancelTimeoutFn := context.WithTimeout(ctx, 5*time.Second)
 		defer cancelTimeoutFn()
 
 		for _, profile := range s.profiles.profiles {
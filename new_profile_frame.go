@@ -2,9 +2,16 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/SeungKang/wgui/internal/atomicfile"
+	"github.com/SeungKang/wgui/internal/wguctl/wgconf"
+
+	"gioui.org/io/clipboard"
 	"gioui.org/layout"
 	"gioui.org/op/paint"
 	"gioui.org/text"
@@ -31,7 +38,7 @@ func (s *State) renderNewProfileFrame(ctx context.Context, gtx layout.Context) l
 func (s *State) renderNewProfileContent(ctx context.Context, gtx layout.Context) layout.Dimensions {
 	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
 		layout.Flexed(1, func(gtx C) D {
-			return s.renderProfileForm(gtx)
+			return s.renderProfileForm(ctx, gtx)
 		}),
 		layout.Rigid(func(gtx C) D {
 			return s.renderFormActionBar(ctx, gtx)
@@ -40,14 +47,19 @@ func (s *State) renderNewProfileContent(ctx context.Context, gtx layout.Context)
 }
 
 // renderProfileForm displays the scrollable form with name and config fields
-func (s *State) renderProfileForm(gtx layout.Context) layout.Dimensions {
+func (s *State) renderProfileForm(ctx context.Context, gtx layout.Context) layout.Dimensions {
 	form := []layout.Widget{
 		func(gtx C) D { return s.renderSpacer(gtx, unit.Dp(16)) },
+		func(gtx C) D { return s.renderEditConflict(ctx, gtx) },
+		func(gtx C) D { return s.renderOverwritePrompt(ctx, gtx) },
 		s.formField("Name", s.profileNameEditor, unit.Dp(30)),
-		s.formField("Config", s.configEditor, unit.Dp(300)),
+		s.renderProfileNameError,
+		s.renderConfigField,
+		s.renderConfigDiagnostics,
 	}
 
 	s.handleProfileEditorUpdates(gtx)
+	s.configCheck.update(gtx, s.configEditor)
 
 	return material.List(s.theme, s.sidebarProfilesList).Layout(gtx, len(form), func(gtx C, i int) D {
 		return layout.UniformInset(unit.Dp(16)).Layout(gtx, form[i])
@@ -87,12 +99,89 @@ func (s *State) renderFormButtons(ctx context.Context, gtx layout.Context) layou
 				return s.renderDeleteButton(ctx, gtx)
 			}
 		}),
+		layout.Rigid(func(gtx C) D {
+			if s.currentUiMode == newProfileUiMode || s.editPath == "" {
+				return D{}
+			}
+
+			return s.renderRestoreButton(gtx)
+		}),
 		layout.Flexed(1, func(gtx C) D {
 			return layout.Spacer{}.Layout(gtx)
 		}),
+		layout.Rigid(func(gtx C) D {
+			return s.renderGeneratePrivateKeyButton(gtx)
+		}),
+		layout.Rigid(func(gtx C) D {
+			return s.renderGeneratePresharedKeyButton(gtx)
+		}),
 	)
 }
 
+// renderGeneratePrivateKeyButton shows a button that sets the
+// config's private key to a new one
+func (s *State) renderGeneratePrivateKeyButton(gtx layout.Context) layout.Dimensions {
+	onClick := func() {
+		err := s.generatePrivateKey()
+		if err != nil {
+			s.errLabel = err.Error()
+			s.errLogger.Printf("failed to generate private key - %v", err)
+		}
+	}
+
+	return layout.Inset{Left: unit.Dp(12)}.Layout(gtx, func(gtx C) D {
+		return s.renderButton(gtx, "New Private Key", GreyColor, s.genPrivateKeyButton, onClick)
+	})
+}
+
+// renderGeneratePresharedKeyButton shows a button that copies a new
+// preshared key to the clipboard, so it can be shared with a peer
+func (s *State) renderGeneratePresharedKeyButton(gtx layout.Context) layout.Dimensions {
+	onClick := func() {
+		psk, err := wgconf.GeneratePresharedKey()
+		if err != nil {
+			s.errLabel = err.Error()
+			s.errLogger.Printf("failed to generate preshared key - %v", err)
+			return
+		}
+
+		gtx.Execute(clipboard.WriteCmd{Data: io.NopCloser(strings.NewReader(psk))})
+		s.formInfoMsg = "Copied a new preshared key to the clipboard"
+	}
+
+	return layout.Inset{Left: unit.Dp(12)}.Layout(gtx, func(gtx C) D {
+		return s.renderButton(gtx, "Copy New Preshared Key", GreyColor, s.genPresharedKeyButton, onClick)
+	})
+}
+
+// generatePrivateKey sets the [Interface] PrivateKey in the config
+// editor to a new key, adding the section if it is missing
+func (s *State) generatePrivateKey() error {
+	file, err := wgconf.Parse([]byte(s.configEditor.Text()))
+	if err != nil {
+		return fmt.Errorf("failed to parse config - %w", err)
+	}
+
+	key, err := wgconf.GeneratePrivateKey()
+	if err != nil {
+		return err
+	}
+
+	var iface *wgconf.Section
+	if sections := file.SectionsNamed(wgconf.InterfaceSection); len(sections) > 0 {
+		iface = sections[0]
+	} else {
+		iface = file.AddSection(wgconf.InterfaceSection)
+	}
+
+	iface.Set("PrivateKey", key)
+
+	s.configEditor.SetText(file.String())
+	s.formInfoMsg = "Generated a new private key"
+
+	return nil
+}
+
 // renderDeleteButton shows the delete profile button
 func (s *State) renderDeleteButton(ctx context.Context, gtx layout.Context) layout.Dimensions {
 	onClick := func() {
@@ -126,6 +215,7 @@ func (s *State) renderCancelButton(ctx context.Context, gtx layout.Context) layo
 	onClick := func() {
 		if len(s.profiles.profiles) != 0 {
 			s.currentUiMode = viewProfileUiMode
+			s.stopEditing()
 		}
 	}
 
@@ -134,10 +224,23 @@ func (s *State) renderCancelButton(ctx context.Context, gtx layout.Context) layo
 	})
 }
 
-// renderFormErrorSection displays error messages
+// renderFormErrorSection displays error and info messages
 func (s *State) renderFormErrorSection(gtx layout.Context) layout.Dimensions {
 	return layout.Inset{Top: unit.Dp(8)}.Layout(gtx, func(gtx C) D {
-		return s.renderErrorMessage(gtx, s.errLabel)
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			layout.Rigid(func(gtx C) D {
+				if s.formInfoMsg == "" {
+					return D{}
+				}
+
+				info := material.Label(s.theme, 12, s.formInfoMsg)
+				info.Color = LightGreyColor
+				return info.Layout(gtx)
+			}),
+			layout.Rigid(func(gtx C) D {
+				return s.renderErrorMessage(gtx, s.errLabel)
+			}),
+		)
 	})
 }
 
@@ -188,12 +291,68 @@ func (s *State) saveProfile(ctx context.Context) {
 		return
 	}
 
+	if err := s.profileNameErr(profileName); err != nil {
+		s.errLabel = "Invalid profile name: " + err.Error()
+		s.errLogger.Printf("Invalid profile name %q - %v", profileName, err)
+		return
+	}
+
+	if !s.validateConfig(configContent) {
+		return
+	}
+
 	if err := s.ensureWguDirectory(); err != nil {
 		return
 	}
 
 	configPath := filepath.Join(s.wguConfDir, profileName+".conf")
-	if err := s.writeConfigFile(configPath, configContent); err != nil {
+
+	// Changing the name of an existing profile renames it,
+	// so conflicts are checked against its current config
+	renaming := s.currentUiMode == editProfileUiMode && s.editPath != "" && s.editPath != configPath
+
+	checkPath := configPath
+	if renaming {
+		checkPath = s.editPath
+	}
+
+	conflict, err := s.checkEditConflict(checkPath, configContent)
+	if err != nil {
+		s.errLabel = err.Error()
+		s.errLogger.Printf("failed to check for edit conflicts - %v", err)
+		return
+	}
+
+	if conflict != nil {
+		s.editConflict = conflict
+		s.errLabel = "The config changed on disk - please choose which changes to keep"
+		return
+	}
+
+	// Saving a new profile under a taken name replaces the
+	// existing profile, so the user has to confirm it first
+	if !renaming && configPath != s.editPath {
+		ok, err := s.checkOverwrite(configPath, profileName)
+		if err != nil {
+			s.errLabel = err.Error()
+			s.errLogger.Printf("failed to check for an existing profile - %v", err)
+			return
+		}
+
+		if !ok {
+			s.errLabel = fmt.Sprintf("A profile named %q already exists", profileName)
+			return
+		}
+	}
+
+	if renaming {
+		err = s.renameProfile(ctx, s.editPath, profileName, configContent)
+		if err != nil {
+			s.errLabel = err.Error()
+			s.errLogger.Printf("failed to rename profile - %v", err)
+			return
+		}
+	} else if err := s.writeConfigFile(configPath, configContent); err != nil {
 		return
 	}
 
@@ -224,6 +383,26 @@ func (s *State) validateProfileInput(name, config string) bool {
 	return true
 }
 
+// validateConfig checks the config for problems, allowing it to be
+// saved if there are only warnings
+func (s *State) validateConfig(config string) bool {
+	diagnostics := s.configCheck.validate(config)
+	if !wgconf.HasErrors(diagnostics) {
+		return true
+	}
+
+	numErrs := 0
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity == wgconf.ErrorSeverity {
+			numErrs++
+		}
+	}
+
+	s.errLabel = fmt.Sprintf("Please fix the %d error(s) in the config before saving", numErrs)
+	s.errLogger.Printf("Config has %d error(s)", numErrs)
+	return false
+}
+
 // ensureWguDirectory creates the .wgu directory if it doesn't exist
 func (s *State) ensureWguDirectory() error {
 	if err := os.MkdirAll(s.wguConfDir, 0755); err != nil {
@@ -233,9 +412,17 @@ func (s *State) ensureWguDirectory() error {
 	return nil
 }
 
-// writeConfigFile saves the config content to a file
+// writeConfigFile backs up the current config and then replaces it,
+// so that the previous version is never lost
 func (s *State) writeConfigFile(path, content string) error {
-	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+	if err := s.backupConfig(path); err != nil {
+		s.errLabel = "Failed to back up the previous config: " + err.Error()
+		s.errLogger.Printf("Error backing up config file: %v", err)
+		return err
+	}
+
+	if err := atomicfile.WriteFile(path, []byte(content), 0600); err != nil {
+		s.errLabel = "Failed to save the config: " + err.Error()
 		s.errLogger.Printf("Error saving config file: %v", err)
 		return err
 	}
@@ -246,6 +433,8 @@ func (s *State) writeConfigFile(path, content string) error {
 func (s *State) clearEditors() {
 	s.profileNameEditor.SetText("")
 	s.configEditor.SetText("")
+	s.formInfoMsg = ""
+	s.stopEditing()
 }
 
 // refreshAndSelectProfile refreshes profiles and switches to the new one
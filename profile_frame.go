@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"image/color"
 	"io"
 	"os"
@@ -12,6 +13,7 @@ import (
 
 	"gioui.org/io/clipboard"
 	"gioui.org/layout"
+	"gioui.org/op"
 	"gioui.org/op/paint"
 	"gioui.org/unit"
 	"gioui.org/widget"
@@ -39,7 +41,17 @@ func (s *State) renderProfileContent(ctx context.Context, gtx layout.Context) la
 		layout.Rigid(func(gtx C) D {
 			return s.renderProfileHeader(gtx)
 		}),
+		layout.Rigid(func(gtx C) D {
+			return s.renderConfigChangedBanner(ctx, gtx)
+		}),
+		layout.Rigid(func(gtx C) D {
+			return s.renderProfileTabs(gtx)
+		}),
 		layout.Flexed(1, func(gtx C) D {
+			if s.currentProfileTab == historyProfileTab {
+				return s.renderHistorySection(gtx)
+			}
+
 			return s.renderLogsSection(gtx)
 		}),
 		layout.Rigid(func(gtx C) D {
@@ -48,6 +60,72 @@ func (s *State) renderProfileContent(ctx context.Context, gtx layout.Context) la
 	)
 }
 
+// renderProfileTabs shows the tabs that switch between logs and
+// state history
+func (s *State) renderProfileTabs(gtx layout.Context) layout.Dimensions {
+	for s.logsTabButton.Clicked(gtx) {
+		s.currentProfileTab = logsProfileTab
+	}
+
+	for s.historyTabButton.Clicked(gtx) {
+		s.currentProfileTab = historyProfileTab
+	}
+
+	tab := func(button *widget.Clickable, label string, tab profileTab) layout.FlexChild {
+		return layout.Rigid(func(gtx C) D {
+			return layout.Inset{Right: unit.Dp(8)}.Layout(gtx, func(gtx C) D {
+				btn := material.Button(s.theme, button, label)
+				btn.Background = GreyColor
+				if s.currentProfileTab == tab {
+					btn.Background = SelectedBg
+				}
+				btn.TextSize = unit.Sp(12)
+				btn.Inset = layout.UniformInset(unit.Dp(6))
+				return btn.Layout(gtx)
+			})
+		})
+	}
+
+	return layout.Inset{Left: unit.Dp(16), Right: unit.Dp(16), Bottom: unit.Dp(8)}.Layout(gtx, func(gtx C) D {
+		return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+			tab(s.logsTabButton, "Logs", logsProfileTab),
+			tab(s.historyTabButton, "History", historyProfileTab),
+		)
+	})
+}
+
+// renderHistorySection displays the profile's state transitions,
+// newest first
+func (s *State) renderHistorySection(gtx layout.Context) layout.Dimensions {
+	history := s.profiles.selected().wgu.History()
+
+	return material.List(s.theme, s.historyList).Layout(gtx, len(history), func(gtx C, i int) D {
+		change := history[len(history)-1-i]
+
+		return layout.Inset{
+			Top: unit.Dp(2), Bottom: unit.Dp(2),
+			Left: unit.Dp(16), Right: unit.Dp(8),
+		}.Layout(gtx, func(gtx C) D {
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+				layout.Rigid(func(gtx C) D {
+					line := s.logText(fmt.Sprintf("%s  %s -> %s  (%s)",
+						change.At.Format("15:04:05.000"), change.From, change.To, change.Cause))
+					return line.Layout(gtx)
+				}),
+				layout.Rigid(func(gtx C) D {
+					if change.Err == nil {
+						return D{}
+					}
+
+					errLine := s.logText(change.Err.Error())
+					errLine.Color = LogErrorColor
+					return layout.Inset{Left: unit.Dp(24)}.Layout(gtx, errLine.Layout)
+				}),
+			)
+		})
+	})
+}
+
 // renderProfileHeader shows the profile name and public key with copy button
 func (s *State) renderProfileHeader(gtx layout.Context) layout.Dimensions {
 	return layout.UniformInset(unit.Dp(16)).Layout(gtx, func(gtx C) D {
@@ -60,6 +138,10 @@ func (s *State) renderProfileHeader(gtx layout.Context) layout.Dimensions {
 					return D{}
 				}
 
+				if s.profiles.selected().pubkey == "" && s.profiles.selected().loading {
+					return s.renderLoading(gtx, "loading profile...")
+				}
+
 				return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
 					layout.Rigid(func(gtx C) D {
 						return s.renderPubkey(gtx, "pubkey: "+s.profiles.selected().pubkey)
@@ -69,10 +151,52 @@ func (s *State) renderProfileHeader(gtx layout.Context) layout.Dimensions {
 					}),
 				)
 			}),
+			layout.Rigid(func(gtx C) D {
+				return s.renderWguStatus(gtx)
+			}),
+			layout.Rigid(func(gtx C) D {
+				return s.renderLastHandshake(gtx)
+			}),
 		)
 	})
 }
 
+// renderWguStatus shows the status wgu reported while connected
+func (s *State) renderWguStatus(gtx layout.Context) layout.Dimensions {
+	selected := s.profiles.selected()
+	if selected.state.To != wguctl.ConnectedFsmState {
+		return D{}
+	}
+
+	status, ok := selected.wgu.Status()
+	if !ok {
+		return D{}
+	}
+
+	msg := fmt.Sprintf("peers: %d", status.PeerCount)
+	if status.ListenAddr != "" {
+		msg = "listening on " + status.ListenAddr + ", " + msg
+	}
+
+	label := material.Label(s.theme, 12, msg)
+	label.Color = LightGreyColor
+
+	return layout.Inset{Top: unit.Dp(2)}.Layout(gtx, label.Layout)
+}
+
+// renderLastHandshake shows when the tunnel last completed a handshake
+func (s *State) renderLastHandshake(gtx layout.Context) layout.Dimensions {
+	lastHandshake := s.profiles.selected().lastHandshake
+	if lastHandshake.IsZero() {
+		return D{}
+	}
+
+	label := material.Label(s.theme, 12, "last handshake: "+lastHandshake.Format("2006-01-02 15:04:05"))
+	label.Color = LightGreyColor
+
+	return layout.Inset{Top: unit.Dp(2)}.Layout(gtx, label.Layout)
+}
+
 // renderCopyButton displays an icon button to copy the pubkey
 func (s *State) renderCopyButton(gtx layout.Context) layout.Dimensions {
 	icon, err := widget.NewIcon(icons.ContentContentCopy)
@@ -124,22 +248,32 @@ func (s *State) isCopiedMessageVisible() bool {
 	return time.Since(s.copiedMessageTime) < 2*time.Second
 }
 
-// renderLogsSection displays scrollable logs
+// renderLogsSection displays scrollable logs, one list item per line
 func (s *State) renderLogsSection(gtx layout.Context) layout.Dimensions {
-	logs := s.profiles.selected().wgu.Stderr()
+	logs := s.profiles.selected().logs
+	logs.update()
 
-	return material.List(s.theme, s.logsList).Layout(gtx, 1, func(gtx C, i int) D {
-		row := material.Body1(s.theme, logs)
-		row.State = s.logSelectables
-		row.Color = WhiteColor
-		row.TextSize = unit.Sp(12)
-		row.Font.Typeface = "monospace"
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx C) D {
+			return s.renderLogSearchBar(gtx, logs)
+		}),
+		layout.Flexed(1, func(gtx C) D {
+			return layout.Stack{Alignment: layout.SE}.Layout(gtx,
+				layout.Expanded(func(gtx C) D {
+					dims := material.List(s.theme, logs.list).Layout(gtx, logs.rowCount(), func(gtx C, row int) D {
+						return s.renderLogLine(gtx, logs, logs.rowLine(row))
+					})
 
-		return layout.Inset{
-			Top: unit.Dp(2), Bottom: unit.Dp(0),
-			Left: unit.Dp(16), Right: unit.Dp(8),
-		}.Layout(gtx, row.Layout)
-	})
+					logs.pruneSelectables()
+
+					return dims
+				}),
+				layout.Stacked(func(gtx C) D {
+					return s.renderJumpToLatestButton(gtx, logs)
+				}),
+			)
+		}),
+	)
 }
 
 // renderActionBar contains buttons and error messages
@@ -154,6 +288,12 @@ func (s *State) renderActionBar(ctx context.Context, gtx layout.Context) layout.
 			layout.Rigid(func(gtx C) D {
 				return s.renderActionButtons(ctx, gtx)
 			}),
+			layout.Rigid(func(gtx C) D {
+				return s.renderReconnectStatus(gtx)
+			}),
+			layout.Rigid(func(gtx C) D {
+				return s.renderDiagnosticsMessage(gtx)
+			}),
 			layout.Rigid(func(gtx C) D {
 				// Reserve consistent space for error message
 				minHeight := gtx.Dp(unit.Dp(32)) // adjust as needed (24–32dp looks good)
@@ -167,10 +307,7 @@ func (s *State) renderActionBar(ctx context.Context, gtx layout.Context) layout.
 
 // renderActionButtons shows the Connect and Edit buttons
 func (s *State) renderActionButtons(ctx context.Context, gtx layout.Context) layout.Dimensions {
-	wguConfig := wguctl.Config{
-		ExePath:    s.wguExePath,
-		ConfigPath: s.profiles.selected().configPath,
-	}
+	wguConfig := s.wguConfigFor(s.profiles.selected())
 
 	return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
 		layout.Rigid(func(gtx C) D {
@@ -181,16 +318,97 @@ func (s *State) renderActionButtons(ctx context.Context, gtx layout.Context) lay
 				return s.renderEditButton(gtx)
 			})
 		}),
+		layout.Rigid(func(gtx C) D {
+			if s.logDir == "" {
+				return D{}
+			}
+
+			return layout.Inset{Left: unit.Dp(12)}.Layout(gtx, func(gtx C) D {
+				return s.renderButton(gtx, "Previous Sessions", GreyColor, s.sessionsButton, s.switchToSessionsMode)
+			})
+		}),
+		layout.Rigid(func(gtx C) D {
+			return layout.Inset{Left: unit.Dp(12)}.Layout(gtx, func(gtx C) D {
+				return s.renderDiagnosticsButton(ctx, gtx)
+			})
+		}),
 		layout.Flexed(1, func(gtx C) D {
 			return layout.Spacer{}.Layout(gtx)
 		}),
 	)
 }
 
+// renderReconnectStatus shows the reconnect attempt counter and a
+// countdown to the next attempt while the profile is reconnecting
+func (s *State) renderReconnectStatus(gtx layout.Context) layout.Dimensions {
+	if s.profiles.selected().state.To != wguctl.ReconnectingFsmState {
+		return D{}
+	}
+
+	status := s.profiles.selected().wgu.Reconnect()
+
+	remaining := time.Until(status.Next).Round(time.Second)
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	// Redraw when the countdown ticks over
+	gtx.Execute(op.InvalidateCmd{At: gtx.Now.Add(time.Second)})
+
+	return layout.Inset{Top: unit.Dp(8)}.Layout(gtx, func(gtx C) D {
+		label := material.Label(s.theme, 12, fmt.Sprintf("reconnect attempt %d/%d in %s",
+			status.Attempt, status.MaxAttempts, remaining))
+		label.Color = LightGreyColor
+		return label.Layout(gtx)
+	})
+}
+
+// renderDiagnosticsButton shows the button that exports a diagnostics bundle
+func (s *State) renderDiagnosticsButton(ctx context.Context, gtx layout.Context) layout.Dimensions {
+	onClick := func() {
+		s.startDiagnosticsExport(ctx)
+	}
+
+	if s.exportingDiagnostics {
+		return s.renderLoading(gtx, "exporting diagnostics")
+	}
+
+	return s.renderButton(gtx, "Export Diagnostics", GreyColor, s.diagnosticsButton, onClick)
+}
+
+// renderDiagnosticsMessage shows where the last diagnostics bundle was
+// saved, or why exporting it failed
+func (s *State) renderDiagnosticsMessage(gtx layout.Context) layout.Dimensions {
+	msg, msgColor := s.diagnosticsMsg, LightGreyColor
+	if s.diagnosticsErrMsg != "" {
+		msg, msgColor = s.diagnosticsErrMsg, LogErrorColor
+	}
+
+	if msg == "" {
+		return D{}
+	}
+
+	return layout.Inset{Top: unit.Dp(8)}.Layout(gtx, func(gtx C) D {
+		label := material.Label(s.theme, 12, msg)
+		label.Color = msgColor
+		label.State = s.diagnosticsSelectable
+		return label.Layout(gtx)
+	})
+}
+
 // renderErrorSection displays error messages
 func (s *State) renderErrorSection(gtx layout.Context) layout.Dimensions {
+	errMsg := s.profiles.selected().lastErrMsg
+	if errMsg == "" {
+		state := s.profiles.selected().state
+		isFailed := state.To == wguctl.ErrorFsmState || state.To == wguctl.ReconnectingFsmState
+		if isFailed && state.Err != nil {
+			errMsg = state.Err.Error()
+		}
+	}
+
 	return layout.Inset{Top: unit.Dp(8)}.Layout(gtx, func(gtx C) D {
-		return s.renderErrorMessage(gtx, s.profiles.selected().lastErrMsg)
+		return s.renderErrorMessage(gtx, errMsg)
 	})
 }
 
@@ -217,18 +435,17 @@ func (s *State) getConnectButtonStyle() (string, color.NRGBA) {
 		return "Error", RedColor
 	}
 
-	wguState, lastErr := selected.wgu.State()
-	_ = lastErr
-
-	switch wguState {
+	switch selected.state.To {
 	case wguctl.DisconnectingFsmState:
 		return "Disconnecting...", RedColor
 	case wguctl.DisconnectedFsmState:
 		return "Connect", GreenColor
 	case wguctl.ConnectingFsmState:
-		return "Connecting...", GreenColor
+		return "Cancel Connect", RedColor
 	case wguctl.ConnectedFsmState:
 		return "Disconnect", RedColor
+	case wguctl.ReconnectingFsmState:
+		return "Cancel Reconnect", RedColor
 	case wguctl.ErrorFsmState:
 		return "Error", RedColor
 	default:
@@ -238,12 +455,11 @@ func (s *State) getConnectButtonStyle() (string, color.NRGBA) {
 
 // createConnectButtonHandler returns the appropriate click handler
 func (s *State) createConnectButtonHandler(ctx context.Context, config wguctl.Config) func() {
-	wguState, lastErr := s.profiles.selected().wgu.State()
-	_ = lastErr
+	wguState := s.profiles.selected().state.To
 
 	return func() {
 		switch wguState {
-		case wguctl.ConnectedFsmState, wguctl.ConnectingFsmState:
+		case wguctl.ConnectedFsmState, wguctl.ConnectingFsmState, wguctl.ReconnectingFsmState:
 			_ = s.profiles.selected().wgu.Disconnect(ctx)
 		default:
 			_ = s.profiles.selected().wgu.Connect(ctx, config)
@@ -254,6 +470,11 @@ func (s *State) createConnectButtonHandler(ctx context.Context, config wguctl.Co
 // renderEditButton shows the edit profile button
 func (s *State) renderEditButton(gtx layout.Context) layout.Dimensions {
 	onClick := func() {
+		// Wait until the config has been read at least once
+		if s.profiles.selected().loading && s.profiles.selected().lastReadConfig == "" {
+			return
+		}
+
 		s.switchToEditMode()
 	}
 	return s.renderButton(gtx, "Edit", GreyColor, s.editButton, onClick)
@@ -265,12 +486,20 @@ func (s *State) switchToEditMode() {
 	s.profileNameEditor.SetText(s.profiles.selected().name)
 	s.configEditor.SetText(s.profiles.selected().lastReadConfig)
 	s.errLabel = ""
+	s.formInfoMsg = ""
+	s.startEditing(s.profiles.selected().configPath, s.profiles.selected().lastReadConfig)
 }
 
 // deleteProfile removes the profile config and refreshes the list
 func (s *State) deleteProfile(ctx context.Context) {
 	configPath := s.profiles.selected().configPath
 
+	// Keep a backup so that a profile created later with the
+	// same name can restore it
+	if err := s.backupConfig(configPath); err != nil {
+		s.errLogger.Printf("failed to back up wgu config file: %q - %v", configPath, err)
+	}
+
 	if err := os.Remove(configPath); err != nil {
 		s.errLogger.Printf("failed to remove wgu config file: %q - %v", configPath, err)
 	}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+)
+
+// maxProfileNameLen is the longest profile name allowed.
+const maxProfileNameLen = 64
+
+// profileNameRe matches the characters allowed in profile names.
+// They must start with a letter or digit, so that names such as
+// ".." or "-c" cannot be mistaken for something else.
+var profileNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// reservedProfileNames are device names that cannot be used as
+// file names on Windows, even with an extension. They are rejected
+// on every platform so that profiles can be copied between them.
+var reservedProfileNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM0": true, "COM1": true, "COM2": true, "COM3": true, "COM4": true,
+	"COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT0": true, "LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true,
+	"LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// checkProfileName returns an error explaining why name cannot be
+// used as a profile name, which is also its config's file name.
+func checkProfileName(name string) error {
+	if name == "" {
+		return errors.New("please enter a profile name")
+	}
+
+	if len(name) > maxProfileNameLen {
+		return fmt.Errorf("profile names can be at most %d characters long", maxProfileNameLen)
+	}
+
+	if !profileNameRe.MatchString(name) {
+		return errors.New("profile names can only contain letters, digits, '.', '_' and '-', " +
+			"and must start with a letter or digit")
+	}
+
+	if strings.HasSuffix(name, ".") {
+		return errors.New("profile names cannot end with '.'")
+	}
+
+	device, _, _ := strings.Cut(name, ".")
+	if reservedProfileNames[strings.ToUpper(device)] {
+		return fmt.Errorf("%q is reserved by Windows and cannot be used as a profile name", device)
+	}
+
+	return nil
+}
+
+// profileNameErr checks the name in the name editor, allowing the
+// name of the profile being edited as is, so that profiles created
+// outside of wgui can still be edited
+func (s *State) profileNameErr(name string) error {
+	if s.editPath != "" && name == profileNameForPath(s.editPath) {
+		return nil
+	}
+
+	return checkProfileName(name)
+}
+
+// renderProfileNameError shows why the name in the name editor
+// cannot be used, if it cannot
+func (s *State) renderProfileNameError(gtx layout.Context) layout.Dimensions {
+	name := s.profileNameEditor.Text()
+	if name == "" {
+		return D{}
+	}
+
+	err := s.profileNameErr(name)
+	if err == nil {
+		return D{}
+	}
+
+	label := material.Label(s.theme, 12, err.Error())
+	label.Color = LogErrorColor
+	return label.Layout(gtx)
+}
+
+// checkOverwrite asks the user to confirm replacing an existing
+// profile with a new one, returning false until they have
+func (s *State) checkOverwrite(configPath string, name string) (bool, error) {
+	if name == s.overwriteAllowed {
+		return true, nil
+	}
+
+	_, err := os.Lstat(configPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return true, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("failed to check if profile %q exists - %w", name, err)
+	}
+
+	s.overwritePrompt = name
+	return false, nil
+}
+
+// renderOverwritePrompt asks whether to replace the existing profile
+// that has the name entered for a new profile
+func (s *State) renderOverwritePrompt(ctx context.Context, gtx layout.Context) layout.Dimensions {
+	name := s.overwritePrompt
+	if name == "" || name != s.profileNameEditor.Text() {
+		return D{}
+	}
+
+	overwrite := func() {
+		s.overwriteAllowed = name
+		s.overwritePrompt = ""
+		s.errLabel = ""
+		s.saveProfile(ctx)
+	}
+
+	keepExisting := func() {
+		s.overwritePrompt = ""
+		s.errLabel = ""
+	}
+
+	return layout.Background{}.Layout(gtx,
+		func(gtx C) D {
+			paint.FillShape(gtx.Ops, SelectedBg, clip.Rect{Max: gtx.Constraints.Min}.Op())
+			return D{Size: gtx.Constraints.Min}
+		},
+		func(gtx C) D {
+			return layout.UniformInset(unit.Dp(8)).Layout(gtx, func(gtx C) D {
+				return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+					layout.Rigid(func(gtx C) D {
+						label := material.Label(s.theme, 14,
+							fmt.Sprintf("A profile named %q already exists. Overwrite it?", name))
+						label.Color = HighlightColor
+						return label.Layout(gtx)
+					}),
+					layout.Rigid(func(gtx C) D {
+						return layout.Inset{Top: unit.Dp(8)}.Layout(gtx, func(gtx C) D {
+							return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+								layout.Rigid(func(gtx C) D {
+									return s.renderButton(gtx, "Overwrite", RedColor, s.overwriteButton, overwrite)
+								}),
+								layout.Rigid(func(gtx C) D {
+									return layout.Inset{Left: unit.Dp(12)}.Layout(gtx, func(gtx C) D {
+										return s.renderButton(gtx, "Keep Existing", GreyColor, s.keepExistingButton, keepExisting)
+									})
+								}),
+							)
+						})
+					}),
+				)
+			})
+		},
+	)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckProfileName(t *testing.T) {
+	valid := []string{
+		"default",
+		"home-vpn",
+		"work_2",
+		"a.b",
+		"Console",
+		strings.Repeat("a", maxProfileNameLen),
+	}
+
+	for _, name := range valid {
+		if err := checkProfileName(name); err != nil {
+			t.Errorf("%q: unexpected error - %v", name, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"..",
+		".hidden",
+		"-c",
+		"../escape",
+		"a/b",
+		`a\b`,
+		"with space",
+		"trailing.",
+		"con",
+		"NUL.backup",
+		"lpt1",
+		strings.Repeat("a", maxProfileNameLen+1),
+	}
+
+	for _, name := range invalid {
+		if err := checkProfileName(name); err == nil {
+			t.Errorf("%q: expected an error", name)
+		}
+	}
+}
+
+func TestState_ProfileNameErr(t *testing.T) {
+	s := newTestState(t)
+
+	// Profiles created outside of wgui can keep their name
+	s.startEditing(filepath.Join(s.wguConfDir, "my vpn.conf"), "")
+
+	if err := s.profileNameErr("my vpn"); err != nil {
+		t.Fatalf("unexpected error for the edited profile's name - %v", err)
+	}
+
+	if err := s.profileNameErr("my other vpn"); err == nil {
+		t.Fatal("expected an error when renaming to an invalid name")
+	}
+}
+
+func TestState_CheckOverwrite(t *testing.T) {
+	s := newTestState(t)
+
+	configPath := filepath.Join(s.wguConfDir, "taken.conf")
+
+	ok, err := s.checkOverwrite(configPath, "taken")
+	if err != nil || !ok {
+		t.Fatalf("got %t, %v for a new profile", ok, err)
+	}
+
+	err = os.WriteFile(configPath, []byte("[Interface]\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err = s.checkOverwrite(configPath, "taken")
+	if err != nil || ok {
+		t.Fatalf("got %t, %v for a taken name", ok, err)
+	}
+
+	if s.overwritePrompt != "taken" {
+		t.Fatalf("got prompt %q", s.overwritePrompt)
+	}
+
+	s.overwriteAllowed = "taken"
+
+	ok, err = s.checkOverwrite(configPath, "taken")
+	if err != nil || !ok {
+		t.Fatalf("got %t, %v after confirming", ok, err)
+	}
+}
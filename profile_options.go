@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/SeungKang/wgui/internal/wguctl"
+)
+
+// readyTimeoutDirectiveRe matches a comment in a profile's config that
+// overrides how long wgu is given to become ready, e.g.:
+//
+//	# wgui.ReadyTimeout = 10s
+var readyTimeoutDirectiveRe = regexp.MustCompile(`(?m)^\s*#\s*wgui\.ReadyTimeout\s*=\s*(\S+)\s*$`)
+
+// parseReadyTimeoutDirective returns the ready timeout set in a
+// profile's config, or 0 if it does not set one.
+func parseReadyTimeoutDirective(config string) (time.Duration, error) {
+	m := readyTimeoutDirectiveRe.FindStringSubmatch(config)
+	if m == nil {
+		return 0, nil
+	}
+
+	timeout, err := time.ParseDuration(m[1])
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse wgui.ReadyTimeout %q - %w", m[1], err)
+	}
+
+	if timeout <= 0 {
+		return 0, fmt.Errorf("wgui.ReadyTimeout must be positive - got %s", timeout)
+	}
+
+	return timeout, nil
+}
+
+// wguConfigFor returns the config used to start wgu for a profile
+func (s *State) wguConfigFor(profile *profileConfig) wguctl.Config {
+	return wguctl.Config{
+		ExePath:         s.wguExePath,
+		ConfigPath:      profile.configPath,
+		OptReadyTimeout: s.readyTimeoutFor(profile),
+	}
+}
+
+// readyTimeoutFor returns the ready timeout for a profile, falling
+// back to the global one
+func (s *State) readyTimeoutFor(profile *profileConfig) time.Duration {
+	if profile.readyTimeout > 0 {
+		return profile.readyTimeout
+	}
+
+	return s.readyTimeout
+}
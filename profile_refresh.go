@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// maxRefreshWorkers limits how many profiles are refreshed at once.
+const maxRefreshWorkers = 8
+
+// refreshResult is what refreshing a profile's config found.
+type refreshResult struct {
+	configPath   string
+	config       string
+	pubkey       string
+	readyTimeout time.Duration
+	err          error
+}
+
+// refreshCacheEntry is the last result for a config file along with
+// what the file looked like when it was read.
+type refreshCacheEntry struct {
+	modTime time.Time
+	size    int64
+	hash    [sha256.Size]byte
+	result  refreshResult
+}
+
+// profileRefresher reads profile configs and derives their public keys
+// on background goroutines, delivering results on the event bus.
+// Results are cached by the file's modification time and size, and
+// then by a hash of its contents, so refreshing an unchanged profile
+// does not read it or run wgu again.
+type profileRefresher struct {
+	wguExePath string
+	bus        *eventBus
+	workers    chan struct{}
+
+	mu    sync.Mutex
+	cache map[string]refreshCacheEntry
+
+	// inFlight has the config paths being refreshed. The value
+	// is true if another refresh was requested in the meantime.
+	inFlight map[string]bool
+}
+
+func newProfileRefresher(wguExePath string, bus *eventBus) *profileRefresher {
+	return &profileRefresher{
+		wguExePath: wguExePath,
+		bus:        bus,
+		workers:    make(chan struct{}, min(runtime.NumCPU(), maxRefreshWorkers)),
+		cache:      make(map[string]refreshCacheEntry),
+		inFlight:   make(map[string]bool),
+	}
+}
+
+// request refreshes a profile in the background. If the profile is
+// already being refreshed, it is refreshed once more afterwards.
+func (o *profileRefresher) request(ctx context.Context, id string, configPath string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, ok := o.inFlight[configPath]; ok {
+		o.inFlight[configPath] = true
+		return
+	}
+
+	o.inFlight[configPath] = false
+
+	go o.run(ctx, id, configPath)
+}
+
+// forget removes a config from the cache.
+func (o *profileRefresher) forget(configPath string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	delete(o.cache, configPath)
+}
+
+func (o *profileRefresher) run(ctx context.Context, id string, configPath string) {
+	for {
+		select {
+		case <-ctx.Done():
+			o.mu.Lock()
+			delete(o.inFlight, configPath)
+			o.mu.Unlock()
+			return
+		case o.workers <- struct{}{}:
+		}
+
+		result := o.refresh(ctx, configPath)
+
+		<-o.workers
+
+		o.bus.publishRefresh(id, result)
+
+		o.mu.Lock()
+		again := o.inFlight[configPath]
+		if !again {
+			delete(o.inFlight, configPath)
+			o.mu.Unlock()
+			return
+		}
+
+		o.inFlight[configPath] = false
+		o.mu.Unlock()
+	}
+}
+
+// refresh returns the cached result for a config if the file
+// has not changed, and loads it otherwise.
+func (o *profileRefresher) refresh(ctx context.Context, configPath string) refreshResult {
+	info, err := os.Stat(configPath)
+	if err != nil {
+		return refreshResult{
+			configPath: configPath,
+			err:        fmt.Errorf("failed to stat file %s - %w", configPath, err),
+		}
+	}
+
+	o.mu.Lock()
+	entry, hasEntry := o.cache[configPath]
+	o.mu.Unlock()
+
+	if hasEntry && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		return entry.result
+	}
+
+	config, err := os.ReadFile(configPath)
+	if err != nil {
+		return refreshResult{
+			configPath: configPath,
+			err:        fmt.Errorf("failed to read file %s - %w", configPath, err),
+		}
+	}
+
+	hash := sha256.Sum256(config)
+
+	// The file may have been touched without being changed
+	if !hasEntry || entry.hash != hash {
+		var cacheable bool
+		entry.result, cacheable = o.load(ctx, configPath, string(config))
+		if !cacheable {
+			return entry.result
+		}
+	}
+
+	entry.modTime = info.ModTime()
+	entry.size = info.Size()
+	entry.hash = hash
+
+	o.mu.Lock()
+	o.cache[configPath] = entry
+	o.mu.Unlock()
+
+	return entry.result
+}
+
+// load derives what a profile needs from its config. It returns false
+// if the result should not be cached because it may change even if
+// the config does not.
+func (o *profileRefresher) load(ctx context.Context, configPath string, config string) (refreshResult, bool) {
+	result := refreshResult{
+		configPath: configPath,
+		config:     config,
+	}
+
+	result.readyTimeout, result.err = parseReadyTimeoutDirective(config)
+	if result.err != nil {
+		return result, true
+	}
+
+	pubkey, err := derivePublicKey(config)
+	if err != nil {
+		// wgu may understand configs that wgconf does not
+		pubkey, err = derivePublicKeyWithWgu(ctx, o.wguExePath, configPath, err)
+		if err != nil {
+			result.err = err
+			return result, false
+		}
+	}
+
+	result.pubkey = pubkey
+
+	return result, true
+}
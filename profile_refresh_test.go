@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const (
+	testPrivateKey = "dwdtCnMYpX08FsFyUbJmRd9ML4frwJkqsXf7pR25LCo="
+	testPublicKey  = "hSDwCYkwp1R0i33ctD73Wg2/Og0mOBr066SpjqqbTmo="
+
+	// otherPrivateKey has the same length as testPrivateKey.
+	otherPrivateKey = "XasIfmJKikt54X+Lg4AO5m87sSkmGLb9HC+LJ/+I4Os="
+)
+
+func writeTestConfig(t *testing.T, path string, privateKey string, modTime time.Time) {
+	t.Helper()
+
+	err := os.WriteFile(path, []byte("[Interface]\nPrivateKey = "+privateKey+"\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Chtimes(path, modTime, modTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProfileRefresher_Cache(t *testing.T) {
+	ctx := context.Background()
+	configPath := filepath.Join(t.TempDir(), "test.conf")
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	writeTestConfig(t, configPath, testPrivateKey, modTime)
+
+	refresher := newProfileRefresher("wgu-does-not-exist", newEventBus())
+
+	result := refresher.refresh(ctx, configPath)
+	if result.err != nil {
+		t.Fatal(result.err)
+	}
+
+	if result.pubkey != testPublicKey {
+		t.Fatalf("got pubkey %q, want %q", result.pubkey, testPublicKey)
+	}
+
+	// Same mtime and size, so the file is not read again
+	writeTestConfig(t, configPath, otherPrivateKey, modTime)
+
+	result = refresher.refresh(ctx, configPath)
+	if result.pubkey != testPublicKey {
+		t.Fatalf("expected the cached pubkey - got %q", result.pubkey)
+	}
+
+	// A new mtime causes the file to be read again
+	writeTestConfig(t, configPath, otherPrivateKey, modTime.Add(time.Minute))
+
+	result = refresher.refresh(ctx, configPath)
+	if result.err != nil {
+		t.Fatal(result.err)
+	}
+
+	if result.pubkey == testPublicKey {
+		t.Fatal("got the cached pubkey after the config changed")
+	}
+
+	refresher.forget(configPath)
+
+	if _, ok := refresher.cache[configPath]; ok {
+		t.Fatal("config was not forgotten")
+	}
+}
+
+func TestProfileRefresher_Request(t *testing.T) {
+	ctx := context.Background()
+	configPath := filepath.Join(t.TempDir(), "test.conf")
+
+	writeTestConfig(t, configPath, testPrivateKey, time.Now())
+
+	bus := newEventBus()
+	refresher := newProfileRefresher("wgu-does-not-exist", bus)
+
+	refresher.request(ctx, "test", configPath)
+
+	select {
+	case <-bus.wakeC():
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the refresh")
+	}
+
+	result, ok := bus.drain().refreshes["test"]
+	if !ok {
+		t.Fatal("refresh result was not published")
+	}
+
+	if result.err != nil || result.pubkey != testPublicKey {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestProfileRefresher_MissingFile(t *testing.T) {
+	refresher := newProfileRefresher("wgu-does-not-exist", newEventBus())
+
+	result := refresher.refresh(context.Background(), filepath.Join(t.TempDir(), "missing.conf"))
+	if result.err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// renameProfile saves config to the config at oldPath and then moves
+// it to the config for newName, carrying over the profile's Fsm, logs
+// and connection state. It refuses to replace the config of another
+// profile. Saving first means that a failure leaves either nothing
+// changed, or the new config saved under the old name.
+func (s *State) renameProfile(ctx context.Context, oldPath string, newName string, config string) error {
+	newPath := filepath.Join(s.wguConfDir, newName+".conf")
+
+	taken, err := isOtherConfig(oldPath, newPath)
+	if err != nil {
+		return err
+	}
+
+	if taken {
+		return fmt.Errorf("a profile named %q already exists", newName)
+	}
+
+	err = s.writeConfigFile(oldPath, config)
+	if err != nil {
+		return fmt.Errorf("failed to save config - %w", err)
+	}
+
+	err = os.Rename(oldPath, newPath)
+	if err != nil {
+		return fmt.Errorf("saved the config, but failed to rename it to %q - %w", newName, err)
+	}
+
+	s.moveProfile(ctx, oldPath, newPath)
+
+	return nil
+}
+
+// isOtherConfig reports whether newPath exists and is not the same
+// file as oldPath, which it is when only the case of the name changed
+// on a case-insensitive file system.
+func isOtherConfig(oldPath string, newPath string) (bool, error) {
+	newInfo, err := os.Lstat(newPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("failed to check if %s exists - %w", newPath, err)
+	}
+
+	oldInfo, err := os.Lstat(oldPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat %s - %w", oldPath, err)
+	}
+
+	return !os.SameFile(oldInfo, newInfo), nil
+}
+
+// moveProfile updates the profile whose config was moved from oldPath
+// to newPath, keeping its Fsm, logs and connection state instead of
+// replacing it with a new profile.
+func (s *State) moveProfile(ctx context.Context, oldPath string, newPath string) {
+	for i := range s.profiles.profiles {
+		profile := &s.profiles.profiles[i]
+		if profile.configPath != oldPath {
+			continue
+		}
+
+		oldName := profile.name
+
+		profile.name = profileNameForPath(newPath)
+		profile.configPath = newPath
+
+		if s.editPath == oldPath {
+			s.editPath = newPath
+		}
+
+		s.refresher.forget(oldPath)
+
+		err := profile.wgu.MoveConfig(ctx, newPath)
+		if err != nil {
+			s.errLogger.Printf("failed to move config of profile %q - %v", profile.name, err)
+		}
+
+		err = s.moveConfigBackups(oldName, profile.name)
+		if err != nil {
+			s.errLogger.Printf("failed to move backups of profile %q - %v", profile.name, err)
+		}
+
+		if profile.logFile != nil {
+			err = profile.logFile.Rename(s.logFilePath(profile.name))
+			if err != nil {
+				s.errLogger.Printf("failed to rename log file of profile %q - %v", profile.name, err)
+			}
+		}
+
+		return
+	}
+}
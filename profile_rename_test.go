@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestState(t *testing.T) *State {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	s := &State{
+		wguConfDir: dir,
+		logDir:     filepath.Join(dir, "logs"),
+		errLogger:  log.Default(),
+		bus:        newEventBus(),
+		profiles:   &profileState{},
+	}
+
+	s.refresher = newProfileRefresher("wgu-does-not-exist", s.bus)
+
+	return s
+}
+
+func TestState_RenameProfile(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	t.Cleanup(cancelFn)
+
+	s := newTestState(t)
+
+	alphaPath := filepath.Join(s.wguConfDir, "alpha.conf")
+	betaPath := filepath.Join(s.wguConfDir, "beta.conf")
+
+	writeTestConfig(t, alphaPath, testPrivateKey, time.Now())
+	writeTestConfig(t, betaPath, otherPrivateKey, time.Now())
+
+	err := s.loadProfiles(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.profiles.selectPath(alphaPath)
+	before := *s.profiles.selected()
+
+	_, err = before.logFile.Write([]byte("hello\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.renameProfile(ctx, alphaPath, "beta", "[Interface]\nrenamed\n")
+	if err == nil {
+		t.Fatal("expected renaming to an existing profile to fail")
+	}
+
+	config, err := os.ReadFile(alphaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(config) == "[Interface]\nrenamed\n" {
+		t.Fatal("config was saved although the rename was refused")
+	}
+
+	err = s.renameProfile(ctx, alphaPath, "gamma", "[Interface]\nrenamed\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gammaPath := filepath.Join(s.wguConfDir, "gamma.conf")
+
+	config, err = os.ReadFile(gammaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(config) != "[Interface]\nrenamed\n" {
+		t.Fatalf("got config %q after renaming", config)
+	}
+
+	if _, err := os.Stat(alphaPath); !os.IsNotExist(err) {
+		t.Fatalf("old config was left behind - %v", err)
+	}
+
+	err = s.loadProfiles(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.profiles.profiles) != 2 {
+		t.Fatalf("got %d profiles, want 2", len(s.profiles.profiles))
+	}
+
+	if !s.profiles.selectPath(gammaPath) {
+		t.Fatal("renamed profile was not found")
+	}
+
+	after := s.profiles.selected()
+	if after.name != "gamma" || after.id != before.id || after.wgu != before.wgu {
+		t.Fatalf("profile was not carried over: %+v", after)
+	}
+
+	logData, err := os.ReadFile(s.logFilePath("gamma"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(logData) != "hello\n" {
+		t.Fatalf("got log %q", logData)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/SeungKang/wgui/internal/wguctl"
+	"github.com/SeungKang/wgui/internal/wguctl/wgconf"
+)
+
+// wguPubkeyTimeout bounds how long wgu is given to derive a public key.
+const wguPubkeyTimeout = 10 * time.Second
+
+// derivePublicKey derives the public key of a config without
+// running wgu.
+func derivePublicKey(config string) (string, error) {
+	_, parsed, err := wgconf.ParseConfig([]byte(config))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse config - %w", err)
+	}
+
+	return parsed.PublicKey()
+}
+
+// derivePublicKeyWithWgu asks wgu for the public key of a config.
+// It is a fallback for configs that wgconf cannot parse, and must
+// not be called from the UI goroutine.
+func derivePublicKeyWithWgu(ctx context.Context, wguExePath string, configPath string, nativeErr error) (string, error) {
+	ctx, cancelFn := context.WithTimeout(ctx, wguPubkeyTimeout)
+	defer cancelFn()
+
+	pubkey, err := wguctl.GetPublicKeyFromConfig(ctx, wguctl.Config{
+		ExePath:    wguExePath,
+		ConfigPath: configPath,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get public key - %v - wgu fallback also failed - %w", nativeErr, err)
+	}
+
+	return pubkey, nil
+}
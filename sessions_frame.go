@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// renderSessionsFrame is the main layout for browsing a profile's
+// previous log sessions
+func (s *State) renderSessionsFrame(ctx context.Context, gtx layout.Context) layout.Dimensions {
+	paint.Fill(gtx.Ops, BgColor)
+
+	return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+		layout.Rigid(func(gtx C) D {
+			return s.renderSidebar(ctx, gtx)
+		}),
+		layout.Flexed(1, func(gtx C) D {
+			return s.renderSessionsContent(gtx)
+		}),
+	)
+}
+
+// renderSessionsContent contains the header, session list and session logs
+func (s *State) renderSessionsContent(gtx layout.Context) layout.Dimensions {
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx C) D {
+			return layout.UniformInset(unit.Dp(16)).Layout(gtx, func(gtx C) D {
+				l := material.H5(s.theme, s.profiles.selected().name+" sessions")
+				l.Color = PurpleColor
+				return l.Layout(gtx)
+			})
+		}),
+		layout.Flexed(1, func(gtx C) D {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Rigid(func(gtx C) D {
+					return s.renderSessionList(gtx)
+				}),
+				layout.Flexed(1, func(gtx C) D {
+					return s.renderSessionLines(gtx)
+				}),
+			)
+		}),
+		layout.Rigid(func(gtx C) D {
+			return layout.UniformInset(unit.Dp(16)).Layout(gtx, func(gtx C) D {
+				return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+					layout.Rigid(func(gtx C) D {
+						return s.renderButton(gtx, "Back", GreyColor, s.backButton, func() {
+							s.currentUiMode = viewProfileUiMode
+						})
+					}),
+					layout.Rigid(func(gtx C) D {
+						return s.renderFormErrorSection(gtx)
+					}),
+				)
+			})
+		}),
+	)
+}
+
+// renderSessionList shows the sessions, newest first
+func (s *State) renderSessionList(gtx layout.Context) layout.Dimensions {
+	width := gtx.Dp(unit.Dp(200))
+	gtx.Constraints.Min.X, gtx.Constraints.Max.X = width, width
+
+	if len(s.sessions) == 0 {
+		return layout.Inset{Left: unit.Dp(16)}.Layout(gtx, func(gtx C) D {
+			l := material.Body2(s.theme, "no previous sessions")
+			l.Color = LightGreyColor
+			return l.Layout(gtx)
+		})
+	}
+
+	return material.List(s.theme, s.sessionsList).Layout(gtx, len(s.sessions), func(gtx C, i int) D {
+		for s.sessionClicks[i].Clicked(gtx) {
+			s.selectedSession = i
+			s.sessionLinesList.Position = layout.Position{}
+		}
+
+		return s.sessionClicks[i].Layout(gtx, func(gtx C) D {
+			if i == s.selectedSession {
+				paint.FillShape(gtx.Ops, SelectedBg, clip.Rect{Max: gtx.Constraints.Max}.Op())
+			}
+
+			return layout.Inset{
+				Top: unit.Dp(6), Bottom: unit.Dp(6),
+				Left: unit.Dp(16), Right: unit.Dp(8),
+			}.Layout(gtx, func(gtx C) D {
+				started := s.sessions[i].started
+				if started == "" {
+					started = "unknown start"
+				}
+
+				l := material.Body2(s.theme, fmt.Sprintf("%s (%d lines)", started, len(s.sessions[i].lines)))
+				l.Color = WhiteColor
+				return l.Layout(gtx)
+			})
+		})
+	})
+}
+
+// renderSessionLines shows the log lines of the selected session
+func (s *State) renderSessionLines(gtx layout.Context) layout.Dimensions {
+	if s.selectedSession >= len(s.sessions) {
+		return D{}
+	}
+
+	lines := s.sessions[s.selectedSession].lines
+
+	return material.List(s.theme, s.sessionLinesList).Layout(gtx, len(lines), func(gtx C, i int) D {
+		return layout.Inset{
+			Top: unit.Dp(1), Bottom: unit.Dp(1),
+			Left: unit.Dp(16), Right: unit.Dp(8),
+		}.Layout(gtx, s.logText(lines[i]).Layout)
+	})
+}
+
+// switchToSessionsMode reads the selected profile's log files and
+// shows its previous sessions
+func (s *State) switchToSessionsMode() {
+	s.errLabel = ""
+
+	sessions, err := readLogSessions(s.logFilePath(s.profiles.selected().name))
+	if err != nil {
+		s.errLabel = err.Error()
+		s.errLogger.Printf("failed to read log sessions - %v", err)
+	}
+
+	s.sessions = sessions
+	s.sessionClicks = make([]widget.Clickable, len(sessions))
+	s.selectedSession = 0
+	s.sessionsList.Position = layout.Position{}
+	s.sessionLinesList.Position = layout.Position{}
+	s.currentUiMode = sessionsUiMode
+}
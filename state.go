@@ -3,13 +3,17 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/SeungKang/wgui/internal/dirwatch"
+	"github.com/SeungKang/wgui/internal/rotlog"
 	"github.com/SeungKang/wgui/internal/wguctl"
 
 	"gioui.org/app"
@@ -29,14 +33,27 @@ type State struct {
 	sidebarProfilesList *widget.List
 
 	// profile_frame
-	pubkeySelectable  *widget.Selectable
-	copyIconButton    *widget.Clickable
-	copiedMessageTime time.Time
-	connectButton     *widget.Clickable
-	editButton        *widget.Clickable
-	errorSelectable   *widget.Selectable
-	logsList          *widget.List
-	logSelectables    *widget.Selectable
+	pubkeySelectable      *widget.Selectable
+	copyIconButton        *widget.Clickable
+	copiedMessageTime     time.Time
+	connectButton         *widget.Clickable
+	editButton            *widget.Clickable
+	sessionsButton        *widget.Clickable
+	diagnosticsButton     *widget.Clickable
+	diagnosticsMsg        string
+	diagnosticsErrMsg     string
+	exportingDiagnostics  bool
+	diagnosticsSelectable *widget.Selectable
+	errorSelectable       *widget.Selectable
+	logsTabButton         *widget.Clickable
+	historyTabButton      *widget.Clickable
+	historyList           *widget.List
+	currentProfileTab     profileTab
+
+	showConfigDiffButton   *widget.Clickable
+	reconnectChangedButton *widget.Clickable
+	showConfigDiff         bool
+	configDiffList         *widget.List
 
 	// new_profile_frame
 	profileNameEditor *widget.Editor
@@ -44,6 +61,35 @@ type State struct {
 	saveButton        *widget.Clickable
 	cancelButton      *widget.Clickable
 	deleteButton      *widget.Clickable
+	configCheck       configCheck
+
+	keepMineButton *widget.Clickable
+	takeDiskButton *widget.Clickable
+	mergeButton    *widget.Clickable
+	editPath       string
+	editBase       string
+	editBaseSet    bool
+	editConflict   *editConflict
+
+	overwriteButton    *widget.Clickable
+	keepExistingButton *widget.Clickable
+	overwritePrompt    string
+	overwriteAllowed   string
+
+	restoreButton   *widget.Clickable
+	restoredBackups int
+
+	genPrivateKeyButton   *widget.Clickable
+	genPresharedKeyButton *widget.Clickable
+	formInfoMsg           string
+
+	// sessions_frame
+	backButton       *widget.Clickable
+	sessions         []logSession
+	sessionClicks    []widget.Clickable
+	selectedSession  int
+	sessionsList     *widget.List
+	sessionLinesList *widget.List
 
 	// window
 	theme    *material.Theme
@@ -52,9 +98,22 @@ type State struct {
 
 	wguConfDir    string
 	wguExePath    string
+	logDir        string
+	readyTimeout  time.Duration
 	errLogger     *log.Logger
 	currentUiMode uiMode
 	profiles      *profileState
+	bus           *eventBus
+	refresher     *profileRefresher
+	nextProfileID int
+}
+
+// reconnectPolicy is how profiles retry after their tunnel drops.
+var reconnectPolicy = wguctl.ReconnectPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: time.Second,
+	MaxBackoff:     30 * time.Second,
+	Jitter:         0.2,
 }
 
 type uiMode int
@@ -63,65 +122,123 @@ const (
 	newProfileUiMode uiMode = iota
 	editProfileUiMode
 	viewProfileUiMode
+	sessionsUiMode
 )
 
+type profileTab int
+
+const (
+	logsProfileTab profileTab = iota
+	historyProfileTab
+)
+
+// stateConfig holds the options wgui was started with.
+type stateConfig struct {
+	// persistLogs saves wgu and wgui logs to rotating files.
+	persistLogs bool
+
+	// readyTimeout is how long wgu is given to become ready unless
+	// a profile overrides it.
+	readyTimeout time.Duration
+}
+
 type profileState struct {
 	profileList   *widget.List
 	profiles      []profileConfig
 	profileClicks []widget.Clickable
 	selectedIndex int
-	events        chan profileEvent
 }
 
-func (o *profileState) selected() *profileConfig {
-	return &o.profiles[o.selectedIndex]
+// selectPath selects the profile with the given config path,
+// returning false if there is none.
+func (o *profileState) selectPath(configPath string) bool {
+	for i, profile := range o.profiles {
+		if profile.configPath == configPath {
+			o.selectedIndex = i
+			return true
+		}
+	}
+
+	return false
 }
 
-type profileEvent struct {
-	name string
+func (o *profileState) selected() *profileConfig {
+	return &o.profiles[o.selectedIndex]
 }
 
 type profileConfig struct {
+	// id identifies the profile on the event bus. Unlike name,
+	// it stays the same when the profile is renamed.
+	id             string
 	name           string
 	configPath     string
 	pubkey         string
 	lastReadConfig string
 	wgu            *wguctl.Fsm
+	state          wguctl.StateChange
+	lastHandshake  time.Time
+	readyTimeout   time.Duration
+	logs           *logView
+	logFile        *rotlog.Writer
 	lastErrMsg     string
+
+	// loading is true while the profile is being refreshed.
+	loading bool
+
+	// runningDiff is how the config changed since wgu was started.
+	runningDiff configDiff
+
+	// configStamp is what the config file looked like when the
+	// profiles were last loaded.
+	configStamp configStamp
 }
 
-func (o *profileConfig) refresh(ctx context.Context, wguExePath string, logger *log.Logger) {
-	err := o.refreshWithErr(ctx, wguExePath)
-	if err != nil {
-		o.lastErrMsg = err.Error()
-		logger.Printf("failed to refresh profile - %v", err)
-	} else {
-		o.lastErrMsg = ""
-	}
+// configStamp identifies a config file by its modification time and
+// size, which renaming it keeps.
+type configStamp struct {
+	modTime time.Time
+	size    int64
 }
 
-func (o *profileConfig) refreshWithErr(ctx context.Context, wguExePath string) error {
-	config, err := os.ReadFile(o.configPath)
+// statConfig returns the stamp of the config file at path, and false
+// if it cannot be read.
+func statConfig(path string) (configStamp, bool) {
+	info, err := os.Stat(path)
 	if err != nil {
-		return fmt.Errorf("failed to read file %s - %v", o.configPath, err)
+		return configStamp{}, false
 	}
 
-	o.lastReadConfig = string(config)
+	return configStamp{modTime: info.ModTime(), size: info.Size()}, true
+}
 
-	pubkey, err := wguctl.GetPublicKeyFromConfig(ctx, wguctl.Config{
-		ExePath:    wguExePath,
-		ConfigPath: o.configPath,
-	})
-	if err != nil {
-		return err
+// applyRefresh updates the profile with the result of refreshing it.
+// A failed refresh replaces what was read before, so that nothing is
+// shown for a config that can no longer be read or is now invalid.
+func (o *profileConfig) applyRefresh(result refreshResult, logger *log.Logger) {
+	o.loading = false
+
+	// result.config is set if the config was read but is invalid
+	o.lastReadConfig = result.config
+	o.readyTimeout = result.readyTimeout
+	o.pubkey = result.pubkey
+
+	if result.err != nil {
+		o.lastErrMsg = result.err.Error()
+		logger.Printf("failed to refresh profile - %v", result.err)
+		return
 	}
 
-	o.pubkey = pubkey
+	o.lastErrMsg = ""
+}
 
-	return nil
+// refreshProfile re-reads a profile's config in the background.
+func (s *State) refreshProfile(ctx context.Context, profile *profileConfig) {
+	profile.loading = true
+
+	s.refresher.request(ctx, profile.id, profile.configPath)
 }
 
-func NewState(ctx context.Context, w *app.Window) *State {
+func NewState(ctx context.Context, w *app.Window, config stateConfig) *State {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		panic(err)
@@ -140,37 +257,68 @@ func NewState(ctx context.Context, w *app.Window) *State {
 				Axis: layout.Vertical,
 			},
 		},
-		copyIconButton:  new(widget.Clickable),
-		connectButton:   new(widget.Clickable),
-		editButton:      new(widget.Clickable),
-		errorSelectable: new(widget.Selectable),
-		logsList: &widget.List{
+		copyIconButton:         new(widget.Clickable),
+		connectButton:          new(widget.Clickable),
+		editButton:             new(widget.Clickable),
+		sessionsButton:         new(widget.Clickable),
+		diagnosticsButton:      new(widget.Clickable),
+		diagnosticsSelectable:  new(widget.Selectable),
+		errorSelectable:        new(widget.Selectable),
+		logsTabButton:          new(widget.Clickable),
+		historyTabButton:       new(widget.Clickable),
+		historyList:            &widget.List{List: layout.List{Axis: layout.Vertical}},
+		pubkeySelectable:       new(widget.Selectable),
+		profileNameEditor:      &widget.Editor{SingleLine: true},
+		configEditor:           new(widget.Editor),
+		saveButton:             new(widget.Clickable),
+		cancelButton:           new(widget.Clickable),
+		deleteButton:           new(widget.Clickable),
+		genPrivateKeyButton:    new(widget.Clickable),
+		keepMineButton:         new(widget.Clickable),
+		takeDiskButton:         new(widget.Clickable),
+		mergeButton:            new(widget.Clickable),
+		overwriteButton:        new(widget.Clickable),
+		keepExistingButton:     new(widget.Clickable),
+		restoreButton:          new(widget.Clickable),
+		showConfigDiffButton:   new(widget.Clickable),
+		reconnectChangedButton: new(widget.Clickable),
+		configDiffList: &widget.List{
 			List: layout.List{
-				Axis:        layout.Vertical,
-				ScrollToEnd: true,
+				Axis: layout.Vertical,
 			},
 		},
-		pubkeySelectable:  new(widget.Selectable),
-		logSelectables:    new(widget.Selectable),
-		profileNameEditor: &widget.Editor{SingleLine: true},
-		configEditor:      new(widget.Editor),
-		saveButton:        new(widget.Clickable),
-		cancelButton:      new(widget.Clickable),
-		deleteButton:      new(widget.Clickable),
-		theme:             material.NewTheme(),
-		win:               w,
+		genPresharedKeyButton: new(widget.Clickable),
+		backButton:            new(widget.Clickable),
+		sessionsList:          &widget.List{List: layout.List{Axis: layout.Vertical}},
+		sessionLinesList:      &widget.List{List: layout.List{Axis: layout.Vertical}},
+		theme:                 material.NewTheme(),
+		win:                   w,
 		profiles: &profileState{
 			profileList: &widget.List{List: layout.List{Axis: layout.Vertical}},
-			events:      make(chan profileEvent),
 		},
+		bus:           newEventBus(),
 		wguConfDir:    filepath.Join(homeDir, ".wgu"),
 		wguExePath:    wguPath,
+		readyTimeout:  config.readyTimeout,
 		errLogger:     log.Default(),
 		currentUiMode: newProfileUiMode,
 	}
 
 	s.theme.Shaper = text.NewShaper(text.WithCollection(gofont.Collection()))
 
+	if config.persistLogs {
+		s.logDir = filepath.Join(s.wguConfDir, "logs")
+
+		wguiLog, err := rotlog.Open(s.logFilePath(wguiLogName), maxLogFileSize, maxLogFiles)
+		if err != nil {
+			s.errLogger.Printf("failed to open wgui log file - %v", err)
+		} else {
+			s.errLogger = log.New(io.MultiWriter(os.Stderr, wguiLog), "", log.LstdFlags)
+		}
+	}
+
+	s.refresher = newProfileRefresher(s.wguExePath, s.bus)
+
 	err = checkWguConf(ctx, wguctl.Config{
 		ExePath:    s.wguExePath,
 		ConfigPath: s.wguConfDir,
@@ -180,11 +328,21 @@ func NewState(ctx context.Context, w *app.Window) *State {
 		panic(err)
 	}
 
+	// Start watching before loading so that no change is missed
+	configWatcher, err := dirwatch.New(s.wguConfDir, "*.conf")
+	if err != nil {
+		s.errLogger.Printf("failed to watch config directory - %v", err)
+	}
+
 	err = s.loadProfiles(ctx)
 	if err != nil {
 		panic(err)
 	}
 
+	if configWatcher != nil {
+		go s.watchConfigDir(ctx, configWatcher)
+	}
+
 	if len(s.profiles.profiles) > 0 {
 		s.currentUiMode = viewProfileUiMode
 	}
@@ -250,16 +408,16 @@ func (s *State) Run(ctx context.Context, w *app.Window) error {
 					s.renderNewProfileFrame(ctx, gtx)
 				case viewProfileUiMode:
 					s.renderProfileFrame(ctx, gtx)
+				case sessionsUiMode:
+					s.renderSessionsFrame(ctx, gtx)
 				}
 
 				e.Frame(gtx.Ops)
 			}
 
 			acks <- struct{}{}
-		case e := <-s.profiles.events:
-			if e.name == s.profiles.selected().name {
-				s.win.Invalidate()
-			}
+		case <-s.bus.wakeC():
+			s.applyBusEvents(ctx, s.bus.drain())
 		}
 	}
 }
@@ -271,6 +429,8 @@ func (s *State) loadProfiles(ctx context.Context) error {
 		return fmt.Errorf("failed to get all .conf paths - %v", err)
 	}
 
+	s.moveRenamedProfiles(ctx, paths)
+
 	var profileConfigs []profileConfig
 
 	visited := make([]bool, len(s.profiles.profiles))
@@ -291,29 +451,56 @@ func (s *State) loadProfiles(ctx context.Context) error {
 		if hasIt {
 			profileConfigs = append(profileConfigs, *existingConfig)
 		} else {
-			baseName := filepath.Base(path)
-			profileName := strings.TrimSuffix(baseName, ".conf")
+			profileName := profileNameForPath(path)
+
+			s.nextProfileID++
+			profileID := strconv.Itoa(s.nextProfileID)
+
+			fsmConfig := wguctl.FsmConfig{
+				OnNewStderr: func(ctx context.Context) {
+					s.bus.publishNewLogs(profileID)
+				},
+				OnStatus: func(ctx context.Context) {
+					s.bus.publishNewStatus(profileID)
+				},
+				Reconnect: reconnectPolicy,
+			}
+
+			logFile := s.openProfileLogFile(profileName)
+			if logFile != nil {
+				fsmConfig.OptLogWriter = logFile
+			}
+
+			fsm := wguctl.NewFsm(ctx, fsmConfig)
+
+			go s.forwardFsmEvents(ctx, profileID, fsm)
 
 			profileConfigs = append(profileConfigs, profileConfig{
+				id:         profileID,
 				name:       profileName,
 				configPath: path,
-				wgu: wguctl.NewFsm(ctx, wguctl.FsmConfig{
-					OnNewStderr: func(ctx context.Context) {
-						select {
-						case <-ctx.Done():
-						case s.profiles.events <- profileEvent{name: profileName}:
-						}
-					},
-				}),
+				state:      wguctl.StateChange{To: wguctl.DisconnectedFsmState},
+				logs:       newLogView(fsm),
+				logFile:    logFile,
+				wgu:        fsm,
 			})
 		}
 
-		profileConfigs[len(profileConfigs)-1].refresh(ctx, s.wguExePath, s.errLogger)
+		profile := &profileConfigs[len(profileConfigs)-1]
+		profile.configStamp, _ = statConfig(path)
+
+		s.refreshProfile(ctx, profile)
 	}
 
 	for i, wasVisited := range visited {
 		if !wasVisited {
-			s.profiles.profiles[i].wgu.Destroy(ctx)
+			removed := s.profiles.profiles[i]
+
+			s.refresher.forget(removed.configPath)
+
+			// Stopping wgu can take up to its grace period,
+			// so do not make the UI wait for it
+			go destroyProfile(ctx, removed.wgu, removed.logFile)
 		}
 	}
 
@@ -322,15 +509,152 @@ func (s *State) loadProfiles(ctx context.Context) error {
 		return profileConfigs[i].name < profileConfigs[j].name
 	})
 
+	selectedPath := ""
+	if s.profiles.selectedIndex < len(s.profiles.profiles) {
+		selectedPath = s.profiles.selected().configPath
+	}
+
 	// Initialize profiles with sorted profiles
 	s.profiles.profiles = profileConfigs
 
+	// Keep the same profile selected even if profiles were added
+	// or removed before it, or select another if it was removed
+	if !s.profiles.selectPath(selectedPath) {
+		s.profiles.selectedIndex = max(min(s.profiles.selectedIndex, len(s.profiles.profiles)-1), 0)
+	}
+
+	if len(s.profiles.profiles) == 0 &&
+		(s.currentUiMode == viewProfileUiMode || s.currentUiMode == sessionsUiMode) {
+		s.currentUiMode = newProfileUiMode
+	}
+
 	// Initialize clickable widgets for all profiles
 	s.profiles.profileClicks = make([]widget.Clickable, len(s.profiles.profiles))
 
 	return nil
 }
 
+// moveRenamedProfiles finds profiles whose configs were renamed
+// outside of wgui since they were loaded, and moves them to their new
+// paths so that they keep their Fsm, logs and connection state rather
+// than being replaced. Like dirwatch, a config that disappeared while
+// a new one with the same modification time and size appeared is
+// treated as renamed.
+func (s *State) moveRenamedProfiles(ctx context.Context, paths []string) {
+	known := make(map[string]bool, len(s.profiles.profiles))
+	for _, profile := range s.profiles.profiles {
+		known[profile.configPath] = true
+	}
+
+	newStamps := make(map[string]configStamp)
+	exists := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		exists[path] = true
+
+		if known[path] {
+			continue
+		}
+
+		stamp, ok := statConfig(path)
+		if ok {
+			newStamps[path] = stamp
+		}
+	}
+
+	var renames []dirwatch.Rename
+
+	for _, profile := range s.profiles.profiles {
+		if exists[profile.configPath] || profile.configStamp == (configStamp{}) {
+			continue
+		}
+
+		for _, path := range paths {
+			stamp, isNew := newStamps[path]
+			if isNew && stamp.size == profile.configStamp.size && stamp.modTime.Equal(profile.configStamp.modTime) {
+				renames = append(renames, dirwatch.Rename{From: profile.configPath, To: path})
+				delete(newStamps, path)
+				break
+			}
+		}
+	}
+
+	for _, rename := range renames {
+		s.moveProfile(ctx, rename.From, rename.To)
+	}
+}
+
+// destroyProfile stops a removed profile's Fsm and then closes its
+// log file, which the Fsm writes to until it is done
+func destroyProfile(ctx context.Context, fsm *wguctl.Fsm, logFile *rotlog.Writer) {
+	fsm.Destroy(ctx)
+
+	if logFile != nil {
+		_ = logFile.Close()
+	}
+}
+
+// profileNameForPath returns the name of the profile whose
+// config is at configPath
+func profileNameForPath(configPath string) string {
+	return strings.TrimSuffix(filepath.Base(configPath), ".conf")
+}
+
+// forwardFsmEvents publishes a profile's Fsm state changes and
+// tunnel events on the event bus until the Fsm is destroyed
+func (s *State) forwardFsmEvents(ctx context.Context, profileID string, fsm *wguctl.Fsm) {
+	changes, unsubscribe := fsm.Subscribe()
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-fsm.Done():
+			return
+		case change := <-changes:
+			s.bus.publishStateChange(profileID, change)
+		case event := <-fsm.Events():
+			if event.Kind == wguctl.HandshakeCompletedEventKind {
+				s.bus.publishHandshake(profileID, event.Time)
+			}
+		}
+	}
+}
+
+// applyBusEvents updates profiles with the notifications drained
+// from the event bus, redrawing if the selected profile changed
+func (s *State) applyBusEvents(ctx context.Context, events busEvents) {
+	if !events.configChanges.IsEmpty() {
+		s.applyConfigChanges(ctx, events.configChanges)
+	}
+
+	if events.diagnostics != nil {
+		s.applyDiagnosticsResult(*events.diagnostics)
+	}
+
+	for i := range s.profiles.profiles {
+		profile := &s.profiles.profiles[i]
+
+		if change, ok := events.stateChanges[profile.id]; ok {
+			profile.state = change
+		}
+
+		if at, ok := events.handshakes[profile.id]; ok {
+			profile.lastHandshake = at
+		}
+
+		if result, ok := events.refreshes[profile.id]; ok && result.configPath == profile.configPath {
+			profile.applyRefresh(result, s.errLogger)
+		}
+	}
+
+	// Refreshes change the sidebar, so they matter for any profile
+	if len(events.refreshes) > 0 || events.diagnostics != nil ||
+		len(s.profiles.profiles) > 0 && events.affects(s.profiles.selected().id) {
+		s.win.Invalidate()
+	}
+}
+
 func (s *State) RefreshProfiles(ctx context.Context) error {
 	err := s.loadProfiles(ctx)
 	if err != nil {
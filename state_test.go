@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestState_LoadProfilesDestroysRemoved(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	t.Cleanup(cancelFn)
+
+	s := newTestState(t)
+
+	configPath := filepath.Join(s.wguConfDir, "removed.conf")
+
+	writeTestConfig(t, configPath, testPrivateKey, time.Now())
+
+	err := s.loadProfiles(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsm := s.profiles.profiles[0].wgu
+
+	err = os.Remove(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.loadProfiles(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.profiles.profiles) != 0 {
+		t.Fatalf("got %d profiles, want 0", len(s.profiles.profiles))
+	}
+
+	select {
+	case <-fsm.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("removed profile's Fsm was not destroyed")
+	}
+}
+
+func TestProfileConfig_ApplyRefreshErrorClearsStaleData(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+
+	profile := profileConfig{}
+	profile.applyRefresh(refreshResult{
+		config:       "[Interface]\nPrivateKey = " + testPrivateKey + "\n",
+		pubkey:       testPublicKey,
+		readyTimeout: time.Minute,
+	}, logger)
+
+	if profile.pubkey != testPublicKey || profile.lastErrMsg != "" {
+		t.Fatalf("unexpected profile after refresh: %+v", profile)
+	}
+
+	// The config was read, but is now invalid
+	const invalidConfig = "[Interface]\nPrivateKey = nope\n"
+
+	profile.applyRefresh(refreshResult{
+		config: invalidConfig,
+		err:    errors.New("invalid key"),
+	}, logger)
+
+	if profile.lastErrMsg == "" {
+		t.Fatal("refresh error was not recorded")
+	}
+
+	if profile.lastReadConfig != invalidConfig || profile.pubkey != "" || profile.readyTimeout != 0 {
+		t.Fatalf("stale data kept after a failed refresh: %+v", profile)
+	}
+
+	// The config can no longer be read
+	profile.applyRefresh(refreshResult{err: errors.New("file not found")}, logger)
+
+	if profile.lastReadConfig != "" {
+		t.Fatalf("stale config kept after a failed refresh: %q", profile.lastReadConfig)
+	}
+}
+
+func TestState_LoadProfilesKeepsRenamedProfiles(t *testing.T) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	t.Cleanup(cancelFn)
+
+	s := newTestState(t)
+
+	alphaPath := filepath.Join(s.wguConfDir, "alpha.conf")
+	gammaPath := filepath.Join(s.wguConfDir, "gamma.conf")
+
+	writeTestConfig(t, alphaPath, testPrivateKey, time.Now().Add(-time.Hour))
+
+	err := s.loadProfiles(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := s.profiles.profiles[0]
+
+	_, err = before.logFile.Write([]byte("hello\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rename the config behind wgui's back, and reload without
+	// the config watcher having seen the rename
+	err = os.Rename(alphaPath, gammaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.loadProfiles(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.profiles.profiles) != 1 {
+		t.Fatalf("got %d profiles, want 1", len(s.profiles.profiles))
+	}
+
+	after := s.profiles.profiles[0]
+	if after.name != "gamma" || after.configPath != gammaPath || after.id != before.id || after.wgu != before.wgu {
+		t.Fatalf("profile was not carried over: %+v", after)
+	}
+
+	select {
+	case <-after.wgu.Done():
+		t.Fatal("renamed profile's Fsm was destroyed")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	logData, err := os.ReadFile(s.logFilePath("gamma"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(logData) != "hello\n" {
+		t.Fatalf("got log %q", logData)
+	}
+}
@@ -45,7 +45,7 @@ func (s *State) renderSidebar(ctx context.Context, gtx layout.Context) layout.Di
 			layout.Flexed(1, func(gtx C) D {
 				return material.List(s.theme, s.profiles.profileList).Layout(gtx, len(s.profiles.profiles), func(gtx C, i int) D {
 					for s.profiles.profileClicks[i].Clicked(gtx) {
-						s.profiles.profiles[i].refresh(ctx, s.wguExePath, s.errLogger)
+						s.refreshProfile(ctx, &s.profiles.profiles[i])
 
 						s.profiles.selectedIndex = i
 						s.currentUiMode = viewProfileUiMode
@@ -60,9 +60,20 @@ func (s *State) renderSidebar(ctx context.Context, gtx layout.Context) layout.Di
 
 						pad := layout.Inset{Top: unit.Dp(6), Bottom: unit.Dp(6), Left: unit.Dp(8), Right: unit.Dp(8)}
 						return pad.Layout(gtx, func(gtx C) D {
-							lbl := material.Body1(s.theme, s.profiles.profiles[i].name)
-							lbl.Color = WhiteColor
-							return lbl.Layout(gtx)
+							return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+								layout.Flexed(1, func(gtx C) D {
+									lbl := material.Body1(s.theme, s.profiles.profiles[i].name)
+									lbl.Color = WhiteColor
+									return lbl.Layout(gtx)
+								}),
+								layout.Rigid(func(gtx C) D {
+									if !s.profiles.profiles[i].loading {
+										return D{}
+									}
+
+									return s.renderSpinner(gtx)
+								}),
+							)
 						})
 					}
 
@@ -88,6 +99,8 @@ func (s *State) renderSidebarButtons(ctx context.Context, gtx layout.Context) la
 				s.profileNameEditor.SetText("")
 				s.configEditor.SetText("")
 				s.errLabel = ""
+				s.formInfoMsg = ""
+				s.stopEditing()
 				s.currentUiMode = newProfileUiMode
 				s.win.Invalidate()
 			}
@@ -135,6 +148,12 @@ func (s *State) renderSidebarRefreshButton(ctx context.Context, gtx layout.Conte
 }
 
 func (s *State) renderTextEditor(gtx layout.Context, editor *widget.Editor, placeholder string, height unit.Dp) layout.Dimensions {
+	return s.renderTextEditorWithOverlay(gtx, editor, placeholder, height, nil)
+}
+
+// renderTextEditorWithOverlay is renderTextEditor with overlay, if
+// it is not nil, laid out over the editor in the editor's coordinates
+func (s *State) renderTextEditorWithOverlay(gtx layout.Context, editor *widget.Editor, placeholder string, height unit.Dp, overlay layout.Widget) layout.Dimensions {
 	h := gtx.Dp(height)
 	gtx.Constraints.Min.Y = h
 	gtx.Constraints.Max.Y = h
@@ -164,7 +183,14 @@ func (s *State) renderTextEditor(gtx layout.Context, editor *widget.Editor, plac
 				ed := material.Editor(s.theme, editor, placeholder)
 				ed.Color = WhiteColor
 				ed.TextSize = unit.Sp(14)
-				return ed.Layout(gtx)
+				dims := ed.Layout(gtx)
+
+				if overlay != nil {
+					defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+					overlay(gtx)
+				}
+
+				return dims
 			})
 		}),
 	)
@@ -212,6 +238,31 @@ func (s *State) renderPubkey(gtx layout.Context, message string) layout.Dimensio
 	}.Layout(gtx, pubkeyLabel.Layout)
 }
 
+// renderSpinner shows a small animated loading indicator
+func (s *State) renderSpinner(gtx layout.Context) layout.Dimensions {
+	size := gtx.Dp(unit.Dp(12))
+	gtx.Constraints.Min = image.Pt(size, size)
+	gtx.Constraints.Max = gtx.Constraints.Min
+
+	loader := material.Loader(s.theme)
+	loader.Color = LightGreyColor
+	return loader.Layout(gtx)
+}
+
+// renderLoading shows a spinner next to a message
+func (s *State) renderLoading(gtx layout.Context, message string) layout.Dimensions {
+	return layout.Inset{Top: unit.Dp(2)}.Layout(gtx, func(gtx C) D {
+		return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+			layout.Rigid(s.renderSpinner),
+			layout.Rigid(func(gtx C) D {
+				label := material.Label(s.theme, 12, message)
+				label.Color = LightGreyColor
+				return layout.Inset{Left: unit.Dp(6)}.Layout(gtx, label.Layout)
+			}),
+		)
+	})
+}
+
 func (s *State) renderSpacer(gtx layout.Context, height unit.Dp) layout.Dimensions {
 	return layout.Spacer{Height: height}.Layout(gtx)
 }